@@ -0,0 +1,188 @@
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ksahli/baal/pkg/loader"
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+var ErrFailed = errors.New("some targets failed")
+
+type Command struct {
+	Definitions string
+	Format      string
+	Match       string
+	Concurrency int
+	Timeout     time.Duration
+	JSON        bool
+	Output      io.Writer
+}
+
+func (c Command) Execute(ctx context.Context) error {
+	logger := log.New(os.Stderr, " [baal] ", log.Ldate)
+
+	output := c.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	var format loader.Format
+	if c.Format != "" {
+		named, err := loader.Named(c.Format)
+		if err != nil {
+			err := fmt.Errorf("check: %w", err)
+			return err
+		}
+		format = named
+	}
+
+	var match *regexp.Regexp
+	if c.Match != "" {
+		compiled, err := regexp.Compile(c.Match)
+		if err != nil {
+			err := fmt.Errorf("check: %w", err)
+			return err
+		}
+		match = compiled
+	}
+
+	source, err := loader.File(c.Definitions, format, logger)
+	if err != nil {
+		err := fmt.Errorf("check: %w", err)
+		return err
+	}
+
+	definitions, err := source.Definitions()
+	if err != nil {
+		err := fmt.Errorf("check: %w", err)
+		return err
+	}
+
+	jobs := []monitor.Job{}
+	for _, definition := range definitions {
+		if match != nil && !match.MatchString(definition.Location) {
+			continue
+		}
+		job, err := definition.Job()
+		if err != nil {
+			err := fmt.Errorf("check: %w", err)
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	client := &http.Client{Timeout: c.Timeout}
+	stamper := time.Now
+	monitor := monitor.New(client, stamper)
+
+	results := c.probe(ctx, monitor, jobs)
+
+	if c.JSON {
+		err = encode(output, results)
+	} else {
+		err = table(output, results)
+	}
+	if err != nil {
+		err := fmt.Errorf("check: %w", err)
+		return err
+	}
+
+	for _, result := range results {
+		if !result.Passed {
+			err := fmt.Errorf("check: %w", ErrFailed)
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Command) probe(ctx context.Context, m *monitor.Monitor, jobs []monitor.Job) []monitor.Result {
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	// Canceling ctx also aborts the probes already in flight.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.Abort()
+		case <-done:
+		}
+	}()
+
+	results := make([]monitor.Result, len(jobs))
+	wg, slots := new(sync.WaitGroup), make(chan struct{}, concurrency)
+	for i, job := range jobs {
+		if err := acquire(ctx, slots); err != nil {
+			results[i] = monitor.Result{
+				Location: job.Location,
+				Failure:  monitor.Canceled,
+				Error:    err.Error(),
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, job monitor.Job) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = m.Do(job)
+		}(i, job)
+	}
+	wg.Wait()
+	return results
+}
+
+func acquire(ctx context.Context, slots chan struct{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func encode(output io.Writer, results []monitor.Result) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+func table(output io.Writer, results []monitor.Result) error {
+	writer := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "LOCATION\tSTATUS\tRESULT\tTIME\tDETAILS")
+	for _, result := range results {
+		outcome, details := "ok", strings.Join(result.Violations, "; ")
+		switch {
+		case result.Failure != "":
+			outcome, details = string(result.Failure), result.Error
+		case !result.Passed:
+			outcome = "fail"
+		}
+		status := "-"
+		if result.Reachable {
+			status = fmt.Sprintf("%d", result.Status)
+		}
+		total := result.Timings.Total.Round(time.Millisecond)
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", result.Location, status, outcome, total, details)
+	}
+	return writer.Flush()
+}
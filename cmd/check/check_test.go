@@ -0,0 +1,202 @@
+package check_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/cmd/check"
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+var ctx = context.Background()
+
+func definitions(t *testing.T, locations ...string) string {
+	entries := []string{}
+	for _, location := range locations {
+		entry := fmt.Sprintf(`{"location": %q, "method": "GET", "frequency": "1m", "expect": {"status": ["2xx"]}}`, location)
+		entries = append(entries, entry)
+	}
+	path := fmt.Sprintf("%s/definitions.json", t.TempDir())
+	content := fmt.Sprintf("[%s]", strings.Join(entries, ","))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	return path
+}
+
+func server(t *testing.T, status int) *httptest.Server {
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handle))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExecute(t *testing.T) {
+	up, other := server(t, 200), server(t, 204)
+	output := new(bytes.Buffer)
+	cmd := check.Command{
+		Definitions: definitions(t, up.URL, other.URL),
+		Concurrency: 2,
+		Output:      output,
+	}
+
+	if err := cmd.Execute(ctx); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		msg := "want a header and 2 rows, got %q"
+		t.Fatalf(msg, output.String())
+	}
+	for i, want := range []string{up.URL + " ", other.URL + " "} {
+		if !strings.HasPrefix(lines[i+1], want) || !strings.Contains(lines[i+1], " ok ") {
+			msg := "want a passing row for %s, got %q"
+			t.Fatalf(msg, want, lines[i+1])
+		}
+	}
+}
+
+func TestExecuteFailed(t *testing.T) {
+	up, down := server(t, 200), server(t, 500)
+	output := new(bytes.Buffer)
+	cmd := check.Command{
+		Definitions: definitions(t, up.URL, down.URL),
+		JSON:        true,
+		Output:      output,
+	}
+
+	if err := cmd.Execute(ctx); !errors.Is(err, check.ErrFailed) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, check.ErrFailed, err)
+	}
+
+	results := []monitor.Result{}
+	if err := json.Unmarshal(output.Bytes(), &results); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	if len(results) != 2 {
+		msg := "want 2 results, got %d"
+		t.Fatalf(msg, len(results))
+	}
+	if !results[0].Passed || results[1].Passed {
+		msg := "want the first result to pass and the second to fail, got %v"
+		t.Fatalf(msg, results)
+	}
+}
+
+func TestExecuteMatch(t *testing.T) {
+	up, down := server(t, 200), server(t, 500)
+	output := new(bytes.Buffer)
+	cmd := check.Command{
+		Definitions: definitions(t, up.URL, down.URL+"/down"),
+		Match:       "/down$",
+		JSON:        true,
+		Output:      output,
+	}
+
+	if err := cmd.Execute(ctx); !errors.Is(err, check.ErrFailed) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, check.ErrFailed, err)
+	}
+
+	results := []monitor.Result{}
+	if err := json.Unmarshal(output.Bytes(), &results); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	if len(results) != 1 || results[0].Location.Path != "/down" {
+		msg := "want only the matching target, got %v"
+		t.Fatalf(msg, results)
+	}
+}
+
+func TestExecuteCanceled(t *testing.T) {
+	up := server(t, 200)
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	cmd := check.Command{
+		Definitions: definitions(t, up.URL, up.URL+"/other"),
+		Output:      new(bytes.Buffer),
+	}
+
+	if err := cmd.Execute(ctx); !errors.Is(err, check.ErrFailed) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, check.ErrFailed, err)
+	}
+}
+
+func TestExecuteCanceledInFlight(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	output := new(bytes.Buffer)
+	cmd := check.Command{
+		Definitions: definitions(t, slow.URL),
+		Timeout:     30 * time.Second,
+		JSON:        true,
+		Output:      output,
+	}
+
+	start := time.Now()
+	if err := cmd.Execute(ctx); !errors.Is(err, check.ErrFailed) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, check.ErrFailed, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		msg := "want the probe aborted on cancel, took %s"
+		t.Fatalf(msg, elapsed)
+	}
+
+	results := []monitor.Result{}
+	if err := json.Unmarshal(output.Bytes(), &results); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	if len(results) != 1 || results[0].Failure != monitor.Canceled {
+		msg := "want a canceled result, got %v"
+		t.Fatalf(msg, results)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	valid := definitions(t, "https://domain-1.com")
+	tests := map[string]check.Command{
+		"path":        {Definitions: "testdata/missing.json"},
+		"format":      {Definitions: valid, Format: "xml"},
+		"match":       {Definitions: valid, Match: "("},
+		"definitions": {Definitions: definitions(t, "domain-1.com")},
+	}
+	for name, cmd := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd.Output = new(bytes.Buffer)
+			err := cmd.Execute(ctx)
+			if err == nil || errors.Is(err, check.ErrFailed) {
+				msg := "want a command error, got %v"
+				t.Fatalf(msg, err)
+			}
+		})
+	}
+}
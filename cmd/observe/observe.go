@@ -2,70 +2,457 @@ package observe
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ksahli/baal/pkg/collector"
+	"github.com/ksahli/baal/pkg/exporter"
 	"github.com/ksahli/baal/pkg/loader"
 	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/notifier"
+	"github.com/ksahli/baal/pkg/pool"
 	"github.com/ksahli/baal/pkg/ticker"
+	"github.com/ksahli/baal/pkg/tracker"
+	"github.com/ksahli/baal/pkg/watcher"
 )
 
+var ErrDeadline = errors.New("drain deadline exceeded")
+
 type Command struct {
 	Definitions string
+	Format      string
 	Results     string
+	Output      string
+	Metrics     string
+	Sinks       string
+	Store       string
+	Rotation    collector.Rotation
+	Timeout     time.Duration
+	Workers     int
+	HostLimit   int
+	Queue       int
+	Stats       time.Duration
+	Schedule    ticker.Options
+	Reload      time.Duration
+	Drain       time.Duration
+	Notifiers   string
+	Maintenance []tracker.Window
 }
 
 func (c Command) Execute(ctx context.Context) error {
 	logger := log.New(os.Stderr, " [baal] ", log.Ldate)
 
-	loader, err := loader.File(c.Definitions, logger)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	// The watcher takes its first look at the file before loading it, so
+	// edits made while starting up are picked up as well.
+	watcher := watcher.New(c.Definitions, c.Reload, signals)
+
+	definitions, err := c.load(logger)
 	if err != nil {
 		err := fmt.Errorf("observe: %w", err)
 		return err
 	}
 
-	client := new(http.Client)
+	routes, err := c.routes()
+	if err != nil {
+		err := fmt.Errorf("observe: %w", err)
+		return err
+	}
+
+	client := &http.Client{Timeout: c.Timeout}
 	stamper := time.Now
 	monitor := monitor.New(client, stamper)
 
-	collector, err := collector.File(c.Results, logger)
+	exporter := exporter.New(nil)
+	fanout, server, err := c.fanout(logger, exporter)
 	if err != nil {
 		err := fmt.Errorf("observe failed: %w", err)
 		return err
 	}
 
-	cwg, mwg := new(sync.WaitGroup), new(sync.WaitGroup)
+	var (
+		cwg, twg, nwg = new(sync.WaitGroup), new(sync.WaitGroup), new(sync.WaitGroup)
+		pwg, fwg, awg = new(sync.WaitGroup), new(sync.WaitGroup), new(sync.WaitGroup)
+	)
 
-	cwg.Add(1)
-	go collector.Run(cwg, monitor.Results())
+	tracker := tracker.New(definitions.policies)
+	twg.Add(1)
+	go tracker.Run(twg, monitor.Results())
+	exporter.Track(tracker.Stats)
 
-	frequencies, err := loader.Load()
-	if err != nil {
-		err := fmt.Errorf("observe: %w", err)
-		return err
-	}
+	notifier := notifier.New(routes, logger)
+	notifier.Update(definitions.recipients)
+	notifier.Start(nwg)
+
+	cwg.Add(2)
+	go fanout.Run(cwg, tracker.Results())
+	go record(cwg, logger, tracker.Events(), notifier)
 
-	for frequency, jobs := range frequencies {
-		ticker := ticker.New(frequency, jobs)
+	pool := pool.New(monitor, c.Workers, c.HostLimit, c.Queue)
+	pool.Start(pwg)
 
-		for i := 0; i <= 10; i++ {
-			mwg.Add(1)
-			go monitor.Run(mwg, ticker.Jobsc())
+	ticker := ticker.New(definitions.entries, c.Schedule)
+
+	exporter.Gauge("baal_queue_depth", "Jobs scheduled by the ticker waiting for the worker pool.", func() float64 {
+		return float64(len(ticker.Jobsc()))
+	})
+	exporter.Gauge("baal_pool_queued", "Jobs queued for the workers.", func() float64 {
+		return float64(pool.Stats().Queued)
+	})
+	exporter.Gauge("baal_pool_busy", "Workers running a probe.", func() float64 {
+		return float64(pool.Stats().Busy)
+	})
+	exporter.Gauge("baal_pool_waiting", "Jobs waiting on a host limit.", func() float64 {
+		return float64(pool.Stats().Waiting)
+	})
+	exporter.Counter("baal_pool_dropped_total", "Jobs dropped on shutdown or because their host backlog was full.", func() float64 {
+		return float64(pool.Stats().Dropped)
+	})
+	sinks := func(value func(collector.SinkStats) float64) func() map[string]float64 {
+		return func() map[string]float64 {
+			values := map[string]float64{}
+			for _, stats := range fanout.Stats() {
+				values[stats.Name] = value(stats)
+			}
+			return values
 		}
+	}
+	exporter.Family("baal_sink_written_total", "Results written by each sink.", "counter", "sink",
+		sinks(func(stats collector.SinkStats) float64 { return float64(stats.Written) }))
+	exporter.Family("baal_sink_dropped_total", "Results dropped by each sink because its buffer was full.", "counter", "sink",
+		sinks(func(stats collector.SinkStats) float64 { return float64(stats.Dropped) }))
+	exporter.Family("baal_sink_errors_total", "Results each sink failed to write.", "counter", "sink",
+		sinks(func(stats collector.SinkStats) float64 { return float64(stats.Errors) }))
+	exporter.Family("baal_sink_queued", "Results waiting in the buffer of each sink.", "gauge", "sink",
+		sinks(func(stats collector.SinkStats) float64 { return float64(stats.Queued) }))
+
+	fwg.Add(1)
+	go pool.Feed(fwg, ticker.Jobsc())
+
+	go ticker.Tick(ctx)
+
+	reopens := make(chan os.Signal, 1)
+	signal.Notify(reopens, syscall.SIGHUP)
+	defer signal.Stop(reopens)
+
+	awg.Add(3)
+	go func() {
+		defer awg.Done()
+		watcher.Watch(ctx)
+	}()
+	go c.reload(awg, logger, watcher.Changes(), ticker, tracker, notifier, exporter)
+	go reopen(ctx, awg, reopens, fanout)
 
-		go ticker.Tick(ctx)
+	if c.Stats > 0 {
+		awg.Add(1)
+		go report(ctx, awg, logger, pool, fanout, c.Stats)
 	}
 
-	mwg.Wait()
+	// Shutdown goes upstream to downstream: the ticker stops producing jobs,
+	// the workers finish the probes in flight, then the results left are
+	// tracked, notified and written before the collector is closed.
+	<-ctx.Done()
+	deadline := time.Now().Add(c.Drain)
+	stats := pool.Stats()
+	logger.Printf("shutting down: waiting for %d probes in flight, dropping %d queued", stats.Busy, stats.Queued)
+	pool.Stop()
+
+	ticker.Stop()
+	awg.Wait()
+
+	fwg.Wait()
+	pool.Close()
+
+	drained := c.drain(pwg, deadline)
+	if !drained {
+		logger.Printf("drain deadline of %s exceeded, aborting probes in flight", c.Drain)
+		monitor.Abort()
+		pwg.Wait()
+	}
 	monitor.Stop()
+	client.CloseIdleConnections()
+
+	twg.Wait()
+	tracker.Stop()
 
 	cwg.Wait()
-	collector.Stop()
+	notifier.Close()
+	if !c.drain(nwg, deadline) {
+		logger.Printf("drain deadline of %s exceeded, aborting notifications in flight", c.Drain)
+		notifier.Abort()
+		nwg.Wait()
+		drained = false
+	}
+	fanout.Stop()
+	for _, stats := range fanout.Stats() {
+		logger.Printf("sink %s: %d written, %d dropped, %d errors", stats.Name, stats.Written, stats.Dropped, stats.Errors)
+	}
+	if server != nil {
+		server.Close()
+	}
 
+	if !drained {
+		err := fmt.Errorf("observe: %w", ErrDeadline)
+		return err
+	}
 	return nil
 }
+
+// drain waits for the workers to finish their probes, or the notifiers to
+// deliver their messages, up to the drain deadline when there is one.
+func (c Command) drain(wg *sync.WaitGroup, deadline time.Time) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	if c.Drain <= 0 {
+		<-done
+		return true
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// fanout builds the sinks of the results: the results file, metrics address
+// and store given on the command line, then the ones of the sinks file. The
+// results file is only required when there is no other sink.
+func (c Command) fanout(logger *log.Logger, exporter *exporter.Exporter) (*collector.Fanout, *http.Server, error) {
+	var (
+		fanout = collector.NewFanout(logger)
+		server *http.Server
+		sinks  = []collector.SinkConfig{}
+	)
+	if c.Results != "" || (c.Metrics == "" && c.Sinks == "" && c.Store == "") {
+		results, err := c.results(logger)
+		if err != nil {
+			err := fmt.Errorf("sink results: %w", err)
+			return nil, nil, err
+		}
+		fanout.Add("results", results, 0)
+	}
+	if c.Metrics != "" {
+		sinks = append(sinks, collector.SinkConfig{Name: "metrics", Type: collector.MetricsSink, Address: c.Metrics})
+	}
+	if c.Store != "" {
+		sinks = append(sinks, collector.SinkConfig{Name: "store", Type: collector.StoreSink, Path: c.Store})
+	}
+	if c.Sinks != "" {
+		config, err := collector.ConfigFile(c.Sinks, nil)
+		if err != nil {
+			fanout.Stop()
+			return nil, nil, err
+		}
+		sinks = append(sinks, config.Sinks...)
+	}
+
+	fail := func(name string, err error) (*collector.Fanout, *http.Server, error) {
+		fanout.Stop()
+		if server != nil {
+			server.Close()
+		}
+		err = fmt.Errorf("sink %s: %w", name, err)
+		return nil, nil, err
+	}
+	for index, config := range sinks {
+		name := config.Label(index)
+		if config.Type != collector.MetricsSink {
+			sink, err := config.Sink(logger)
+			if err != nil {
+				return fail(name, err)
+			}
+			fanout.Add(name, sink, config.Buffer)
+			continue
+		}
+		if server != nil {
+			return fail(name, errors.New("metrics are already served"))
+		}
+		var err error
+		if server, err = serve(logger, config.Address, exporter); err != nil {
+			return fail(name, err)
+		}
+		record := func(result monitor.Result) error {
+			exporter.Record(result)
+			return nil
+		}
+		fanout.Add(name, collector.SinkFunc(record), config.Buffer)
+	}
+	return fanout, server, nil
+}
+
+func (c Command) results(logger *log.Logger) (*collector.Collector, error) {
+	var format collector.Format
+	if c.Output != "" {
+		named, err := collector.Named(c.Output)
+		if err != nil {
+			return nil, err
+		}
+		format = named
+	}
+	return collector.File(c.Results, format, c.Rotation, logger)
+}
+
+// serve exposes the exporter on /metrics. The address is bound before
+// returning so a busy port fails the startup.
+func serve(logger *log.Logger, address string, exporter *exporter.Exporter) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Printf("metrics server failed: %v", err)
+		}
+	}()
+	logger.Printf("serving metrics on http://%s/metrics", listener.Addr())
+	return server, nil
+}
+
+type loaded struct {
+	entries    []ticker.Entry
+	policies   map[string]tracker.Policy
+	recipients map[string][]string
+}
+
+func (c Command) load(logger *log.Logger) (loaded, error) {
+	var format loader.Format
+	if c.Format != "" {
+		named, err := loader.Named(c.Format)
+		if err != nil {
+			return loaded{}, err
+		}
+		format = named
+	}
+
+	loader, err := loader.File(c.Definitions, format, logger)
+	if err != nil {
+		return loaded{}, err
+	}
+	definitions, err := loader.Definitions()
+	if err != nil {
+		return loaded{}, err
+	}
+
+	result := loaded{
+		entries:    make([]ticker.Entry, 0, len(definitions)),
+		policies:   map[string]tracker.Policy{},
+		recipients: map[string][]string{},
+	}
+	for _, definition := range definitions {
+		entry, err := definition.Entry()
+		if err != nil {
+			return loaded{}, err
+		}
+		policy, err := definition.Policy()
+		if err != nil {
+			return loaded{}, err
+		}
+		policy.Maintenance = append(policy.Maintenance, c.Maintenance...)
+
+		key := entry.Job.Key()
+		result.entries = append(result.entries, entry)
+		result.policies[key] = policy
+		result.recipients[key] = append(result.recipients[key], definition.Recipients...)
+	}
+	return result, nil
+}
+
+func (c Command) reload(wg *sync.WaitGroup, logger *log.Logger, reloads <-chan struct{}, ticker *ticker.Ticker, tracker *tracker.Tracker, notifier *notifier.Notifier, exporter *exporter.Exporter) {
+	defer wg.Done()
+	for range reloads {
+		definitions, err := c.load(logger)
+		if err != nil {
+			logger.Printf("reload of %s failed, keeping current definitions: %v", c.Definitions, err)
+			continue
+		}
+		keys := make([]string, 0, len(definitions.entries))
+		for _, entry := range definitions.entries {
+			keys = append(keys, entry.Job.Key())
+		}
+		tracker.Update(definitions.policies)
+		notifier.Update(definitions.recipients)
+		exporter.Update(keys)
+		changes := ticker.Update(definitions.entries)
+		logger.Printf("reloaded %s: %d added, %d removed, %d rescheduled, %d updated",
+			c.Definitions, changes.Added, changes.Removed, changes.Rescheduled, changes.Updated)
+	}
+}
+
+func (c Command) routes() ([]notifier.Route, error) {
+	if c.Notifiers == "" {
+		return nil, nil
+	}
+	config, err := notifier.File(c.Notifiers, nil)
+	if err != nil {
+		return nil, err
+	}
+	return config.Routes()
+}
+
+func record(wg *sync.WaitGroup, logger *log.Logger, events <-chan tracker.Event, notifications *notifier.Notifier) {
+	defer wg.Done()
+	for event := range events {
+		text := notifier.NewMessage(event).Text
+		if event.Maintenance {
+			text = "maintenance, not notified: " + text
+		}
+		logger.Print(text)
+		notifications.Notify(event)
+	}
+}
+
+// reopen opens the results files again on SIGHUP, once logrotate or a
+// similar tool has moved them away. The signal reloads the definitions as
+// well, which leaves the schedule of unchanged jobs as it is.
+func reopen(ctx context.Context, wg *sync.WaitGroup, signals <-chan os.Signal, fanout *collector.Fanout) {
+	defer wg.Done()
+	for {
+		select {
+		case <-signals:
+			fanout.Reopen()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func report(ctx context.Context, wg *sync.WaitGroup, logger *log.Logger, pool *pool.Pool, fanout *collector.Fanout, interval time.Duration) {
+	defer wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			stats := pool.Stats()
+			logger.Printf("pool: %d/%d workers busy (%.0f%%), %d waiting on hosts, %d/%d queued",
+				stats.Busy, stats.Workers, stats.Utilisation*100, stats.Waiting, stats.Queued, stats.Capacity)
+			for _, stats := range fanout.Stats() {
+				logger.Printf("sink %s: %d written, %d dropped, %d errors, %d/%d queued",
+					stats.Name, stats.Written, stats.Dropped, stats.Errors, stats.Queued, stats.Capacity)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
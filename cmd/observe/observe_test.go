@@ -2,13 +2,26 @@ package observe_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/ksahli/baal/cmd/observe"
+	"github.com/ksahli/baal/pkg/store"
+	"github.com/ksahli/baal/pkg/ticker"
+	"github.com/ksahli/baal/pkg/tracker"
 )
 
 var ctx = context.Background()
@@ -19,6 +32,11 @@ func TestExecute(t *testing.T) {
 	cmd := observe.Command{
 		Definitions: "testdata/definitions.json",
 		Results:     path,
+		Workers:     4,
+		HostLimit:   1,
+		Queue:       10,
+		Stats:       time.Second,
+		Schedule:    ticker.Options{Immediate: true},
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -36,7 +54,7 @@ func TestExecute(t *testing.T) {
 			t.Fatalf(msg, err)
 		}
 
-		if content == nil {
+		if len(content) == 0 {
 			t.Fatalf("want content written to results file, got nothing")
 		}
 	}()
@@ -74,13 +92,638 @@ func TestExecuteInvalidDefinitions(t *testing.T) {
 	}
 }
 
+func TestExecuteInvalidFormat(t *testing.T) {
+	directory := t.TempDir()
+	path := fmt.Sprintf("%s/results.json", directory)
+	cmd := observe.Command{
+		Definitions: "testdata/definitions.json",
+		Format:      "xml",
+		Results:     path,
+	}
+
+	if err := cmd.Execute(ctx); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+}
+
+func TestExecuteInvalidOutput(t *testing.T) {
+	directory := t.TempDir()
+	path := fmt.Sprintf("%s/results.json", directory)
+	cmd := observe.Command{
+		Definitions: "testdata/definitions.json",
+		Results:     path,
+		Output:      "xml",
+	}
+
+	if err := cmd.Execute(ctx); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+}
+
 func TestExecuteInvaidResultsPath(t *testing.T) {
+	directory := t.TempDir()
+	path := fmt.Sprintf("%s/missing/results.json", directory)
 	cmd := observe.Command{
 		Definitions: "testdata/definitions.json",
-		Results:     "/invalid",
+		Results:     path,
 	}
 
 	if err := cmd.Execute(ctx); err == nil {
 		t.Fatal("want an error, got nothing")
 	}
 }
+
+func TestExecuteReload(t *testing.T) {
+	reloaded := make(chan struct{})
+	var once sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/after" {
+			once.Do(func() { close(reloaded) })
+		}
+	}))
+	defer server.Close()
+
+	directory := t.TempDir()
+	definitions := fmt.Sprintf("%s/definitions.json", directory)
+	write := func(path string) {
+		content := fmt.Sprintf(`[{"location": "%s%s", "frequency": "1h"}]`, server.URL, path)
+		if err := os.WriteFile(definitions, []byte(content), 0644); err != nil {
+			msg := "unwanted error: %v"
+			t.Fatalf(msg, err)
+		}
+	}
+	write("/before")
+
+	cmd := observe.Command{
+		Definitions: definitions,
+		Results:     fmt.Sprintf("%s/results.json", directory),
+		Schedule:    ticker.Options{Immediate: true},
+		Reload:      20 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- cmd.Execute(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	write("/after")
+
+	select {
+	case <-reloaded:
+	case <-ctx.Done():
+		t.Fatal("want the reloaded definition to run, got nothing")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+}
+
+func TestExecuteDrain(t *testing.T) {
+	tests := map[string]struct {
+		delay time.Duration
+		drain time.Duration
+		want  error
+		line  string
+	}{
+		"drained": {
+			delay: 200 * time.Millisecond,
+			drain: 5 * time.Second,
+			line:  `"Status":200`,
+		},
+		"deadline exceeded": {
+			delay: 5 * time.Second,
+			drain: 100 * time.Millisecond,
+			want:  observe.ErrDeadline,
+			line:  `"Failure":"canceled"`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				select {
+				case <-time.After(test.delay):
+				case <-r.Context().Done():
+				}
+			}))
+			defer server.Close()
+
+			directory := t.TempDir()
+			definitions := fmt.Sprintf("%s/definitions.json", directory)
+			content := fmt.Sprintf(`[{"location": "%s", "frequency": "1h"}]`, server.URL)
+			if err := os.WriteFile(definitions, []byte(content), 0644); err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			results := fmt.Sprintf("%s/results.json", directory)
+
+			cmd := observe.Command{
+				Definitions: definitions,
+				Results:     results,
+				Schedule:    ticker.Options{Immediate: true},
+				Drain:       test.drain,
+			}
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			done := make(chan error)
+			go func() {
+				done <- cmd.Execute(ctx)
+			}()
+
+			<-started
+			cancel()
+
+			if err := <-done; !errors.Is(err, test.want) {
+				msg := "want %v, got %v"
+				t.Fatalf(msg, test.want, err)
+			}
+
+			written, err := os.ReadFile(results)
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			if !strings.Contains(string(written), test.line) {
+				msg := "want a result with %s, got %s"
+				t.Fatalf(msg, test.line, written)
+			}
+		})
+	}
+}
+
+func TestExecuteLeaks(t *testing.T) {
+	// Signal handling starts a process wide goroutine the first time it is
+	// used, it is not a leak of Execute.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	signal.Stop(signals)
+
+	baseline := runtime.NumGoroutine()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	directory := t.TempDir()
+	definitions := fmt.Sprintf("%s/definitions.json", directory)
+	content := fmt.Sprintf(`[{"location": "%s", "frequency": "1s"}, {"location": "%s", "schedule": "* * * * *"}]`, server.URL, server.URL)
+	if err := os.WriteFile(definitions, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	cmd := observe.Command{
+		Definitions: definitions,
+		Results:     fmt.Sprintf("%s/results.json", directory),
+		Workers:     4,
+		Stats:       10 * time.Millisecond,
+		Schedule:    ticker.Options{Immediate: true},
+		Reload:      10 * time.Millisecond,
+		Drain:       time.Second,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	if err := cmd.Execute(ctx); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	server.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			stack := make([]byte, 1<<16)
+			stack = stack[:runtime.Stack(stack, true)]
+			msg := "want %d goroutines once returned, got %d\n%s"
+			t.Fatalf(msg, baseline, runtime.NumGoroutine(), stack)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExecuteNotify(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	notified := make(chan string, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		select {
+		case notified <- string(body):
+		default:
+		}
+	}))
+	defer webhook.Close()
+
+	directory := t.TempDir()
+	definitions := fmt.Sprintf("%s/definitions.json", directory)
+	content := fmt.Sprintf(`[{"location": "%s", "frequency": "1h", "expect": {"status": ["2xx"]}}]`, target.URL)
+	if err := os.WriteFile(definitions, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	notifiers := fmt.Sprintf("%s/notifiers.json", directory)
+	content = fmt.Sprintf(`{"webhooks": [{"url": "%s", "template": "{{.Type}} {{.Target}}"}]}`, webhook.URL)
+	if err := os.WriteFile(notifiers, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	cmd := observe.Command{
+		Definitions: definitions,
+		Results:     fmt.Sprintf("%s/results.json", directory),
+		Schedule:    ticker.Options{Immediate: true},
+		Notifiers:   notifiers,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- cmd.Execute(ctx)
+	}()
+
+	select {
+	case got := <-notified:
+		if want := "degraded " + target.URL; got != want {
+			msg := "want %q, got %q"
+			t.Fatalf(msg, want, got)
+		}
+	case <-ctx.Done():
+		t.Fatal("want a notification, got nothing")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+}
+
+func TestExecuteNotifyDrain(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	attempted := make(chan struct{}, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case attempted <- struct{}{}:
+		default:
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer webhook.Close()
+
+	directory := t.TempDir()
+	definitions := fmt.Sprintf("%s/definitions.json", directory)
+	content := fmt.Sprintf(`[{"location": "%s", "frequency": "1h", "expect": {"status": ["2xx"]}}]`, target.URL)
+	if err := os.WriteFile(definitions, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	// Retrying the failing webhook would take about a minute.
+	notifiers := fmt.Sprintf("%s/notifiers.json", directory)
+	content = fmt.Sprintf(`{"webhooks": [{"url": "%s", "retries": 5, "backoff": "2s"}]}`, webhook.URL)
+	if err := os.WriteFile(notifiers, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	cmd := observe.Command{
+		Definitions: definitions,
+		Results:     fmt.Sprintf("%s/results.json", directory),
+		Schedule:    ticker.Options{Immediate: true},
+		Notifiers:   notifiers,
+		Drain:       200 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- cmd.Execute(ctx)
+	}()
+
+	select {
+	case <-attempted:
+	case <-ctx.Done():
+		t.Fatal("want a notification attempted, got nothing")
+	}
+
+	cancel()
+	start := time.Now()
+	if err := <-done; !errors.Is(err, observe.ErrDeadline) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, observe.ErrDeadline, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		msg := "want the retries aborted at the drain deadline, took %s"
+		t.Fatalf(msg, elapsed)
+	}
+}
+
+func TestExecuteInvalidNotifiers(t *testing.T) {
+	directory := t.TempDir()
+	cmd := observe.Command{
+		Definitions: "testdata/definitions.json",
+		Results:     fmt.Sprintf("%s/results.json", directory),
+		Notifiers:   fmt.Sprintf("%s/missing.json", directory),
+	}
+
+	if err := cmd.Execute(ctx); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+}
+
+func TestExecuteMaintenance(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	notified := make(chan string, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		select {
+		case notified <- string(body):
+		default:
+		}
+	}))
+	defer webhook.Close()
+
+	directory := t.TempDir()
+	definitions := fmt.Sprintf("%s/definitions.json", directory)
+	content := fmt.Sprintf(`[{"location": "%s", "frequency": "1h", "expect": {"status": ["2xx"]}}]`, target.URL)
+	if err := os.WriteFile(definitions, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	notifiers := fmt.Sprintf("%s/notifiers.json", directory)
+	content = fmt.Sprintf(`{"webhooks": [{"url": "%s"}]}`, webhook.URL)
+	if err := os.WriteFile(notifiers, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	results := fmt.Sprintf("%s/results.json", directory)
+
+	now := time.Now()
+	cmd := observe.Command{
+		Definitions: definitions,
+		Results:     results,
+		Schedule:    ticker.Options{Immediate: true},
+		Notifiers:   notifiers,
+		Maintenance: []tracker.Window{tracker.Period{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if err := cmd.Execute(ctx); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	select {
+	case got := <-notified:
+		msg := "want no notification during maintenance, got %s"
+		t.Fatalf(msg, got)
+	default:
+	}
+
+	written, err := os.ReadFile(results)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	if !strings.Contains(string(written), `"Maintenance":true`) {
+		msg := "want results marked as in maintenance, got %s"
+		t.Fatalf(msg, written)
+	}
+}
+
+func TestExecuteMetrics(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	directory := t.TempDir()
+	definitions := fmt.Sprintf("%s/definitions.json", directory)
+	content := fmt.Sprintf(`[{"location": "%s", "frequency": "1h"}]`, target.URL)
+	if err := os.WriteFile(definitions, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	cmd := observe.Command{
+		Definitions: definitions,
+		Metrics:     address,
+		Schedule:    ticker.Options{Immediate: true},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- cmd.Execute(ctx)
+	}()
+
+	want, got := fmt.Sprintf(`baal_target_status_code{target="%s",method="GET"} 200`, target.URL), ""
+	for !strings.Contains(got, want) {
+		select {
+		case <-ctx.Done():
+			msg := "want metrics containing %q, got %q"
+			t.Fatalf(msg, want, got)
+		case <-time.After(20 * time.Millisecond):
+		}
+		response, err := http.Get(fmt.Sprintf("http://%s/metrics", address))
+		if err != nil {
+			continue
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		got = string(body)
+	}
+	if !strings.Contains(got, "# TYPE baal_pool_dropped_total counter\nbaal_pool_dropped_total 0\n") {
+		msg := "want the dropped jobs counter, got %q"
+		t.Fatalf(msg, got)
+	}
+	if state := fmt.Sprintf(`baal_target_state{target="%s",method="GET",state="up"} 1`, target.URL); !strings.Contains(got, state) {
+		msg := "want the state of the target, got %q"
+		t.Fatalf(msg, got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+}
+
+func TestExecuteSinks(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	posted := make(chan struct{}, 1)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case posted <- struct{}{}:
+		default:
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	directory := t.TempDir()
+	definitions := fmt.Sprintf("%s/definitions.json", directory)
+	content := fmt.Sprintf(`[{"location": "%s", "frequency": "1h"}]`, target.URL)
+	if err := os.WriteFile(definitions, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	results := fmt.Sprintf("%s/results.csv", directory)
+	sinks := fmt.Sprintf("%s/sinks.json", directory)
+	content = fmt.Sprintf(`{"sinks": [{"type": "http", "url": "%s"}, {"type": "file", "path": "%s"}]}`, failing.URL, results)
+	if err := os.WriteFile(sinks, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	cmd := observe.Command{
+		Definitions: definitions,
+		Sinks:       sinks,
+		Schedule:    ticker.Options{Immediate: true},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- cmd.Execute(ctx)
+	}()
+
+	select {
+	case <-posted:
+	case <-ctx.Done():
+		t.Fatal("want a result posted, got nothing")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	written, err := os.ReadFile(results)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	want := "location,method,status,reachable,time,"
+	if got := string(written); !strings.HasPrefix(got, want) || !strings.Contains(got, target.URL+",GET,200,true,") {
+		msg := "want csv results despite the failing sink, got %q"
+		t.Fatalf(msg, got)
+	}
+}
+
+func TestExecuteInvalidSinks(t *testing.T) {
+	directory := t.TempDir()
+	sinks := fmt.Sprintf("%s/sinks.json", directory)
+	if err := os.WriteFile(sinks, []byte(`{"sinks": [{"type": "kafka"}]}`), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	cmd := observe.Command{
+		Definitions: "testdata/definitions.json",
+		Sinks:       sinks,
+	}
+
+	if err := cmd.Execute(ctx); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+}
+
+func TestExecuteStore(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	directory := t.TempDir()
+	definitions := fmt.Sprintf("%s/definitions.json", directory)
+	content := fmt.Sprintf(`[{"location": "%s", "frequency": "1h"}]`, target.URL)
+	if err := os.WriteFile(definitions, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	dir := fmt.Sprintf("%s/store", directory)
+	cmd := observe.Command{
+		Definitions: definitions,
+		Store:       dir,
+		Schedule:    ticker.Options{Immediate: true},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- cmd.Execute(ctx)
+	}()
+
+	for {
+		segments, _ := filepath.Glob(dir + "/raw/*.ndjson")
+		if len(segments) > 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("want results stored, got nothing")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	results, err := store.New(dir, nil, time.Now)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	defer results.Close()
+	latest, ok, err := results.Latest("GET " + target.URL)
+	if err != nil || !ok || latest.Status != http.StatusOK {
+		msg := "want the result stored, got %v, %t and %v"
+		t.Fatalf(msg, latest, ok, err)
+	}
+}
@@ -0,0 +1,71 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ksahli/baal/pkg/loader"
+)
+
+var ErrInvalid = errors.New("invalid definitions")
+
+type Command struct {
+	Definitions string
+	Format      string
+	Strict      bool
+	Output      io.Writer
+}
+
+func (c Command) Execute(ctx context.Context) error {
+	logger := log.New(os.Stderr, " [baal] ", log.Ldate)
+
+	output := c.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	var format loader.Format
+	if c.Format != "" {
+		named, err := loader.Named(c.Format)
+		if err != nil {
+			err := fmt.Errorf("validate: %w", err)
+			return err
+		}
+		format = named
+	}
+
+	source, err := loader.File(c.Definitions, format, logger)
+	if err != nil {
+		err := fmt.Errorf("validate: %w", err)
+		return err
+	}
+
+	definitions, err := source.Definitions()
+	var problems loader.Problems
+	switch {
+	case errors.As(err, &problems):
+	case err != nil:
+		fmt.Fprintf(output, "%s: %v\n", c.Definitions, err)
+		err := fmt.Errorf("validate: %w", ErrInvalid)
+		return err
+	}
+
+	warnings := loader.Warnings(definitions)
+	for _, problem := range problems {
+		fmt.Fprintf(output, "error   %v\n", problem)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(output, "warning %v\n", warning)
+	}
+	fmt.Fprintf(output, "%s: %d errors, %d warnings\n", c.Definitions, len(problems), len(warnings))
+
+	if len(problems) > 0 || (c.Strict && len(warnings) > 0) {
+		err := fmt.Errorf("validate: %w", ErrInvalid)
+		return err
+	}
+	return nil
+}
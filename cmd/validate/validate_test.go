@@ -0,0 +1,147 @@
+package validate_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ksahli/baal/cmd/validate"
+)
+
+var ctx = context.Background()
+
+func TestExecute(t *testing.T) {
+	output := new(bytes.Buffer)
+	cmd := validate.Command{
+		Definitions: "testdata/valid.json",
+		Output:      output,
+	}
+
+	if err := cmd.Execute(ctx); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	want := "testdata/valid.json: 0 errors, 0 warnings\n"
+	if got := output.String(); got != want {
+		msg := "\n want %q\n got  %q"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestExecuteWarnings(t *testing.T) {
+	tests := map[string]struct {
+		strict bool
+		fails  bool
+	}{
+		"lenient": {strict: false, fails: false},
+		"strict":  {strict: true, fails: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			output := new(bytes.Buffer)
+			cmd := validate.Command{
+				Definitions: "testdata/warnings.yaml",
+				Strict:      test.strict,
+				Output:      output,
+			}
+
+			err := cmd.Execute(ctx)
+			if failed := errors.Is(err, validate.ErrInvalid); failed != test.fails {
+				msg := "want failure %t, got %v"
+				t.Fatalf(msg, test.fails, err)
+			}
+
+			want := "warning definition 0: location: uses plain http\n" +
+				"testdata/warnings.yaml: 0 errors, 1 warnings\n"
+			if got := output.String(); got != want {
+				msg := "\n want %q\n got  %q"
+				t.Fatalf(msg, want, got)
+			}
+		})
+	}
+}
+
+func TestExecuteInvalid(t *testing.T) {
+	output := new(bytes.Buffer)
+	cmd := validate.Command{
+		Definitions: "testdata/invalid.json",
+		Output:      output,
+	}
+
+	if err := cmd.Execute(ctx); !errors.Is(err, validate.ErrInvalid) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, validate.ErrInvalid, err)
+	}
+
+	want := "error   definition 0: expect.status[0]: invalid status \"ok\"\n" +
+		"error   definition 1: frequncy: unknown field\n" +
+		"error   definition 1: location: scheme \"\" is not http or https\n" +
+		"error   definition 1: frequency: is required without a schedule\n" +
+		"testdata/invalid.json: 4 errors, 0 warnings\n"
+	if got := output.String(); got != want {
+		msg := "\n want %q\n got  %q"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestExecuteInvalidWarnings(t *testing.T) {
+	output := new(bytes.Buffer)
+	cmd := validate.Command{
+		Definitions: "testdata/mixed.yaml",
+		Output:      output,
+	}
+
+	if err := cmd.Execute(ctx); !errors.Is(err, validate.ErrInvalid) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, validate.ErrInvalid, err)
+	}
+
+	want := "error   definition 1: frequency: invalid duration \"often\"\n" +
+		"warning definition 0: location: uses plain http\n" +
+		"testdata/mixed.yaml: 1 errors, 1 warnings\n"
+	if got := output.String(); got != want {
+		msg := "\n want %q\n got  %q"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestExecuteMalformed(t *testing.T) {
+	output := new(bytes.Buffer)
+	cmd := validate.Command{
+		Definitions: "testdata/malformed.json",
+		Output:      output,
+	}
+
+	if err := cmd.Execute(ctx); !errors.Is(err, validate.ErrInvalid) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, validate.ErrInvalid, err)
+	}
+	if output.Len() == 0 {
+		t.Fatal("want a report, got nothing")
+	}
+}
+
+func TestExecuteInvalidPath(t *testing.T) {
+	cmd := validate.Command{
+		Definitions: "testdata/missing.json",
+		Output:      new(bytes.Buffer),
+	}
+
+	if err := cmd.Execute(ctx); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+}
+
+func TestExecuteInvalidFormat(t *testing.T) {
+	cmd := validate.Command{
+		Definitions: "testdata/valid.json",
+		Format:      "xml",
+		Output:      new(bytes.Buffer),
+	}
+
+	if err := cmd.Execute(ctx); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+}
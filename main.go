@@ -2,10 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
+	"github.com/ksahli/baal/cmd/check"
 	"github.com/ksahli/baal/cmd/observe"
+	"github.com/ksahli/baal/cmd/validate"
+	"github.com/ksahli/baal/pkg/collector"
+	"github.com/ksahli/baal/pkg/ticker"
+	"github.com/ksahli/baal/pkg/tracker"
 )
 
 type Command interface {
@@ -14,32 +25,141 @@ type Command interface {
 
 var ctx = context.Background()
 
+var errUsage = errors.New("usage: baal <observe|validate|check> [flags]")
+
 func main() {
-	if err := run(ctx); err != nil {
+	if err := run(ctx, os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "baal: %v\n", err)
+		switch {
+		case errors.Is(err, errUsage):
+			os.Exit(2)
+		case errors.Is(err, observe.ErrDeadline):
+			os.Exit(3)
+		}
 		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context) error {
+func run(ctx context.Context, args []string) error {
 	var command Command
 
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	switch os.Args[1] {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	switch args[1] {
 	case "observe":
 		flags := flag.NewFlagSet("observe", flag.ExitOnError)
 		var (
 			definitions = flags.String("definitions", "", "domains definitions file")
-			results     = flags.String("results", "", "monitoring results file")
+			format      = flags.String("format", "", "definitions format: json, yaml or toml (default from file extension)")
+			results     = flags.String("results", "", "monitoring results file, reopened on SIGHUP, which also reloads the definitions")
+			output      = flags.String("output", "", "results format: json, csv or tsv (default from file extension)")
+			metrics     = flags.String("metrics", "", "address serving Prometheus metrics on /metrics, such as :9090, results file optional when set")
+			sinks       = flags.String("sinks", "", "results sinks configuration file, json, yaml or toml, results file optional when set")
+			storage     = flags.String("store", "", "directory of the results store kept for queries, results file optional when set")
+			size        = flags.Int64("rotate-size", 0, "rotate the results file once it reaches this many bytes, 0 to disable")
+			interval    = flags.Duration("rotate-interval", 0, "rotate the results file once it is this old, 0 to disable")
+			compress    = flags.Bool("rotate-compress", false, "gzip the rotated results files")
+			keep        = flags.Int("rotate-keep", 0, "number of rotated results files kept, 0 to keep them all")
+			age         = flags.Duration("rotate-age", 0, "age after which rotated results files are removed, 0 to keep them")
+			timeout     = flags.Duration("timeout", 30*time.Second, "default request timeout")
+			workers     = flags.Int("workers", 10, "number of concurrent monitoring workers")
+			hostLimit   = flags.Int("host-limit", 0, "maximum concurrent requests per host, 0 for no limit")
+			queue       = flags.Int("queue", 100, "number of jobs queued for the workers")
+			stats       = flags.Duration("stats", 0, "interval between worker pool statistics logs, 0 to disable")
+			immediate   = flags.Bool("immediate", true, "run every job once at startup")
+			jitter      = flags.Duration("jitter", 0, "maximum random delay added to each scheduled run")
+			spread      = flags.Bool("spread", false, "spread jobs sharing a frequency evenly across their interval")
+			reload      = flags.Duration("reload", 5*time.Second, "interval between definitions file checks, 0 to reload on SIGHUP only")
+			notifiers   = flags.String("notifiers", "", "notifiers configuration file, json, yaml or toml")
+			drain       = flags.Duration("drain", 10*time.Second, "time left to probes and notifications in flight on shutdown before aborting them, 0 to wait for them")
+			maintenance = []tracker.Window{}
 		)
-		if err := flags.Parse(os.Args[2:]); err != nil {
+		flags.Func("maintenance", "maintenance period muting notifications for every target, as RFC 3339 start/end (repeatable)", func(spec string) error {
+			period, err := tracker.ParsePeriod(spec)
+			if err != nil {
+				return err
+			}
+			maintenance = append(maintenance, period)
+			return nil
+		})
+		if err := flags.Parse(args[2:]); err != nil {
 			return err
 		}
 		command = observe.Command{
 			Definitions: *definitions,
+			Format:      *format,
 			Results:     *results,
+			Output:      *output,
+			Metrics:     *metrics,
+			Sinks:       *sinks,
+			Store:       *storage,
+			Rotation: collector.Rotation{
+				Size:     *size,
+				Interval: *interval,
+				Compress: *compress,
+				Keep:     *keep,
+				Age:      *age,
+			},
+			Timeout:     *timeout,
+			Workers:     *workers,
+			HostLimit:   *hostLimit,
+			Queue:       *queue,
+			Stats:       *stats,
+			Reload:      *reload,
+			Drain:       *drain,
+			Notifiers:   *notifiers,
+			Maintenance: maintenance,
+			Schedule: ticker.Options{
+				Immediate: *immediate,
+				Jitter:    *jitter,
+				Spread:    *spread,
+			},
+		}
+	case "validate":
+		flags := flag.NewFlagSet("validate", flag.ExitOnError)
+		var (
+			definitions = flags.String("definitions", "", "domains definitions file")
+			format      = flags.String("format", "", "definitions format: json, yaml or toml (default from file extension)")
+			strict      = flags.Bool("strict", false, "fail on warnings as well as errors")
+		)
+		if err := flags.Parse(args[2:]); err != nil {
+			return err
+		}
+		command = validate.Command{
+			Definitions: *definitions,
+			Format:      *format,
+			Strict:      *strict,
+			Output:      os.Stdout,
+		}
+	case "check":
+		flags := flag.NewFlagSet("check", flag.ExitOnError)
+		var (
+			definitions = flags.String("definitions", "", "domains definitions file")
+			format      = flags.String("format", "", "definitions format: json, yaml or toml (default from file extension)")
+			match       = flags.String("match", "", "only check locations matching this regular expression")
+			concurrency = flags.Int("concurrency", 10, "maximum number of concurrent requests")
+			timeout     = flags.Duration("timeout", 30*time.Second, "default request timeout")
+			json        = flags.Bool("json", false, "print results as JSON instead of a table")
+		)
+		if err := flags.Parse(args[2:]); err != nil {
+			return err
+		}
+		command = check.Command{
+			Definitions: *definitions,
+			Format:      *format,
+			Match:       *match,
+			Concurrency: *concurrency,
+			Timeout:     *timeout,
+			JSON:        *json,
+			Output:      os.Stdout,
 		}
+	default:
+		return errUsage
 	}
 
 	if err := command.Execute(ctx); err != nil {
@@ -1,31 +1,46 @@
 package collector
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"sync"
+	"time"
 
 	"github.com/ksahli/baal/pkg/monitor"
 )
 
 type Results = <-chan monitor.Result
 
+type syncer interface {
+	Sync() error
+}
+
 type Collector struct {
 	wlock, clock *sync.Mutex
-	encoder      *json.Encoder
+	encoder      Encoder
 	logger       *log.Logger
 
-	closer io.Closer
+	closer  io.Closer
+	format  Format
+	rotator *Rotator
 }
 
 func (c *Collector) Write(result monitor.Result) error {
 	c.wlock.Lock()
 	defer c.wlock.Unlock()
 
-	if err := c.encoder.Encode(&result); err != nil {
+	if c.rotator != nil {
+		rotated, err := c.rotator.Rotate()
+		if err != nil {
+			err := fmt.Errorf("collector error: rotation: %w", err)
+			return err
+		}
+		if rotated {
+			c.encoder = c.format.Encoder(c.rotator, c.rotator.Empty())
+		}
+	}
+	if err := c.encoder.Encode(result); err != nil {
 		return err
 	}
 	return nil
@@ -41,20 +56,45 @@ func (c *Collector) Run(wg *sync.WaitGroup, results Results) {
 	}
 }
 
+// Reopen opens the results file again, for tools moving it away to rotate
+// it themselves. Collectors not writing to a file have nothing to reopen.
+func (c *Collector) Reopen() error {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+
+	if c.rotator == nil {
+		return nil
+	}
+	if err := c.rotator.Reopen(); err != nil {
+		err := fmt.Errorf("collector error: %w", err)
+		return err
+	}
+	c.encoder = c.format.Encoder(c.rotator, c.rotator.Empty())
+	return nil
+}
+
 func (c *Collector) Stop() {
 	c.clock.Lock()
 	defer c.clock.Unlock()
 
+	if syncer, ok := c.closer.(syncer); ok {
+		if err := syncer.Sync(); err != nil {
+			c.logger.Print(err)
+		}
+	}
 	if err := c.closer.Close(); err != nil {
 		c.logger.Print(err)
 	}
 }
 
-func New(writer io.WriteCloser, logger *log.Logger) *Collector {
-	var (
-		wlock, clock = new(sync.Mutex), new(sync.Mutex)
-		encoder      = json.NewEncoder(writer)
-	)
+func New(writer io.WriteCloser, format Format, logger *log.Logger) *Collector {
+	collector := create(writer, format.Encoder(writer, true), logger)
+	collector.format = format
+	return collector
+}
+
+func create(writer io.WriteCloser, encoder Encoder, logger *log.Logger) *Collector {
+	wlock, clock := new(sync.Mutex), new(sync.Mutex)
 	collector := Collector{
 		wlock:   wlock,
 		clock:   clock,
@@ -65,11 +105,19 @@ func New(writer io.WriteCloser, logger *log.Logger) *Collector {
 	return &collector
 }
 
-func File(path string, logger *log.Logger) (*Collector, error) {
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+// File appends the results to path, in the format of its extension when
+// format is nil, and rotates it according to rotation. The header of
+// delimited formats is only written to empty files.
+func File(path string, format Format, rotation Rotation, logger *log.Logger) (*Collector, error) {
+	rotator, err := NewRotator(path, rotation, time.Now, logger)
 	if err != nil {
 		err := fmt.Errorf("collector error: %w", err)
 		return nil, err
 	}
-	return New(file, logger), nil
+	if format == nil {
+		format = Detect(path)
+	}
+	collector := create(rotator, format.Encoder(rotator, rotator.Empty()), logger)
+	collector.format, collector.rotator = format, rotator
+	return collector, nil
 }
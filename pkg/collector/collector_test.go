@@ -20,6 +20,7 @@ import (
 type Writer struct {
 	cfail, wfail bool
 	closed       bool
+	synced       bool
 	results      []monitor.Result
 }
 
@@ -38,6 +39,11 @@ func (w *Writer) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+func (w *Writer) Sync() error {
+	w.synced = !w.closed
+	return nil
+}
+
 func (w *Writer) Close() error {
 	if w.cfail {
 		err := errors.New("closer error")
@@ -66,7 +72,7 @@ func TestWrite(t *testing.T) {
 
 	logger := log.New(os.Stderr, "collector", log.Ldate)
 
-	sut := collector.New(&writer, logger)
+	sut := collector.New(&writer, collector.JSON{}, logger)
 	defer sut.Stop()
 
 	location, err := url.Parse("https://localhost")
@@ -80,6 +86,13 @@ func TestWrite(t *testing.T) {
 		Reachable: false,
 		Status:    200,
 		Time:      time.Now().UTC(),
+		Timings: monitor.Timings{
+			DNS:       time.Millisecond,
+			Connect:   2 * time.Millisecond,
+			TLS:       3 * time.Millisecond,
+			FirstByte: 4 * time.Millisecond,
+			Total:     5 * time.Millisecond,
+		},
 	}
 	if err := sut.Write(want); err != nil {
 		msg := "unwanted error %w"
@@ -101,7 +114,7 @@ func TestWriteError(t *testing.T) {
 
 	logger := log.New(os.Stderr, "collector", log.Ldate)
 
-	sut := collector.New(&writer, logger)
+	sut := collector.New(&writer, collector.JSON{}, logger)
 	defer sut.Stop()
 
 	location, err := url.Parse("https://localhost")
@@ -130,7 +143,7 @@ func TestRun(t *testing.T) {
 	out := new(Out)
 	logger := log.New(out, "collector", log.Ldate)
 
-	sut := collector.New(&writer, logger)
+	sut := collector.New(&writer, collector.JSON{}, logger)
 	defer sut.Stop()
 
 	wg := new(sync.WaitGroup)
@@ -174,7 +187,7 @@ func TestRunWriteError(t *testing.T) {
 	out := new(Out)
 	logger := log.New(out, " [collector] ", log.Ldate)
 
-	sut := collector.New(&writer, logger)
+	sut := collector.New(&writer, collector.JSON{}, logger)
 	defer sut.Stop()
 
 	wg := new(sync.WaitGroup)
@@ -212,6 +225,19 @@ func TestRunWriteError(t *testing.T) {
 	}
 }
 
+func TestStop(t *testing.T) {
+	writer := Writer{}
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+
+	sut := collector.New(&writer, collector.JSON{}, logger)
+	sut.Stop()
+
+	if !writer.synced || !writer.closed {
+		msg := "want the writer synced then closed, got synced %t and closed %t"
+		t.Fatalf(msg, writer.synced, writer.closed)
+	}
+}
+
 func TestRunCloseError(t *testing.T) {
 	writer := Writer{
 		wfail: false,
@@ -221,7 +247,7 @@ func TestRunCloseError(t *testing.T) {
 	out := new(Out)
 	logger := log.New(out, " [collector] ", log.Ldate)
 
-	sut := collector.New(&writer, logger)
+	sut := collector.New(&writer, collector.JSON{}, logger)
 	sut.Stop()
 
 	if len(out.messages) != 1 {
@@ -238,7 +264,7 @@ func TestFile(t *testing.T) {
 		msg := "unwanted error %v"
 		t.Fatalf(msg, err)
 	}
-	collector, err := collector.File(path, logger)
+	collector, err := collector.File(path, nil, collector.Rotation{}, logger)
 	if err != nil {
 		msg := "unwanted error %v"
 		t.Fatalf(msg, err)
@@ -250,7 +276,8 @@ func TestFile(t *testing.T) {
 
 func TestFileError(t *testing.T) {
 	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
-	collector, err := collector.File("/invalid path", logger)
+	path := fmt.Sprintf("%s/missing/results.json", t.TempDir())
+	collector, err := collector.File(path, nil, collector.Rotation{}, logger)
 	if err == nil {
 		t.Fatal("want an error, got nothing")
 	}
@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/ksahli/baal/pkg/loader"
+	"github.com/ksahli/baal/pkg/store"
+)
+
+const defaultTimeout = 10 * time.Second
+
+const (
+	FileSink    = "file"
+	StdoutSink  = "stdout"
+	HTTPSink    = "http"
+	MetricsSink = "metrics"
+	StoreSink   = "store"
+)
+
+type Config struct {
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig describes one sink. Metrics sinks only carry the address to
+// serve them on, they are built by the caller owning the exporter.
+type SinkConfig struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`
+	Buffer  int               `json:"buffer"`
+	Path    string            `json:"path"`
+	Format  string            `json:"format"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Timeout string            `json:"timeout"`
+	Address string            `json:"address"`
+
+	Rotation RotationConfig `json:"rotation"`
+	Tiers    []TierConfig   `json:"tiers"`
+}
+
+// TierConfig is a tier of a store sink, raw when it has no resolution.
+type TierConfig struct {
+	Resolution string `json:"resolution"`
+	Retention  string `json:"retention"`
+}
+
+func (t TierConfig) Tier() (store.Tier, error) {
+	tier := store.Tier{}
+	var err error
+	if t.Resolution != "" {
+		if tier.Resolution, err = loader.Duration(t.Resolution); err != nil {
+			return store.Tier{}, err
+		}
+	}
+	if tier.Retention, err = loader.Duration(t.Retention); err != nil {
+		return store.Tier{}, err
+	}
+	return tier, nil
+}
+
+type RotationConfig struct {
+	Size     int64  `json:"size"`
+	Interval string `json:"interval"`
+	Compress bool   `json:"compress"`
+	Keep     int    `json:"keep"`
+	Age      string `json:"age"`
+}
+
+func (r RotationConfig) Rotation() (Rotation, error) {
+	rotation := Rotation{Size: r.Size, Compress: r.Compress, Keep: r.Keep}
+	var err error
+	if r.Interval != "" {
+		if rotation.Interval, err = loader.Duration(r.Interval); err != nil {
+			return Rotation{}, err
+		}
+	}
+	if r.Age != "" {
+		if rotation.Age, err = loader.Duration(r.Age); err != nil {
+			return Rotation{}, err
+		}
+	}
+	return rotation, rotation.Validate()
+}
+
+// Label returns the name of the sink, its type and index when unnamed.
+func (s SinkConfig) Label(index int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("%s %d", s.Type, index)
+}
+
+func (s SinkConfig) Sink(logger *log.Logger) (Sink, error) {
+	if s.Buffer < 0 {
+		err := fmt.Errorf("negative buffer %d", s.Buffer)
+		return nil, err
+	}
+	var format Format
+	if s.Format != "" {
+		named, err := Named(s.Format)
+		if err != nil {
+			return nil, err
+		}
+		format = named
+	}
+
+	switch s.Type {
+	case FileSink:
+		if s.Path == "" {
+			err := errors.New("missing path")
+			return nil, err
+		}
+		rotation, err := s.Rotation.Rotation()
+		if err != nil {
+			return nil, err
+		}
+		return File(s.Path, format, rotation, logger)
+	case StdoutSink:
+		if format == nil {
+			format = JSON{}
+		}
+		return New(stdout{os.Stdout}, format, logger), nil
+	case StoreSink:
+		if s.Path == "" {
+			err := errors.New("missing path")
+			return nil, err
+		}
+		tiers := make([]store.Tier, 0, len(s.Tiers))
+		for _, config := range s.Tiers {
+			tier, err := config.Tier()
+			if err != nil {
+				return nil, err
+			}
+			tiers = append(tiers, tier)
+		}
+		return Store(s.Path, tiers, logger)
+	case HTTPSink:
+		location, err := url.Parse(s.URL)
+		switch {
+		case err != nil:
+			return nil, err
+		case location.Scheme != "http" && location.Scheme != "https":
+			err := fmt.Errorf("url %q is not http or https", s.URL)
+			return nil, err
+		}
+		sink := HTTP{URL: s.URL, Client: &http.Client{Timeout: defaultTimeout}}
+		if s.Timeout != "" {
+			if sink.Client.Timeout, err = loader.Duration(s.Timeout); err != nil {
+				return nil, err
+			}
+		}
+		if len(s.Headers) > 0 {
+			sink.Header = http.Header{}
+			for name, value := range s.Headers {
+				sink.Header.Set(name, value)
+			}
+		}
+		return sink, nil
+	}
+	err := fmt.Errorf("unknown sink type %q", s.Type)
+	return nil, err
+}
+
+// stdout is never closed by its collector, the process may still print.
+type stdout struct {
+	io.Writer
+}
+
+func (stdout) Close() error {
+	return nil
+}
+
+func ConfigFile(path string, format loader.Format) (Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		err := fmt.Errorf("collector error: %w", err)
+		return Config{}, err
+	}
+	defer file.Close()
+	if format == nil {
+		format = loader.Detect(path)
+	}
+	config := Config{}
+	if err := format.Decode(file, &config); err != nil {
+		err := fmt.Errorf("collector error: %w", err)
+		return Config{}, err
+	}
+	return config, nil
+}
@@ -0,0 +1,128 @@
+package collector_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ksahli/baal/pkg/collector"
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+func TestConfigFile(t *testing.T) {
+	config, err := collector.ConfigFile("testdata/sinks.yaml", nil)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	want := collector.Config{Sinks: []collector.SinkConfig{
+		{
+			Name:     "archive",
+			Type:     collector.FileSink,
+			Path:     "results.csv",
+			Format:   "csv",
+			Buffer:   1000,
+			Rotation: collector.RotationConfig{Size: 104857600, Interval: "24h", Compress: true, Keep: 7},
+		},
+		{Type: collector.StdoutSink, Format: "tsv"},
+		{
+			Name:    "warehouse",
+			Type:    collector.HTTPSink,
+			URL:     "https://ingest.domain.com/results",
+			Headers: map[string]string{"Authorization": "Bearer secret"},
+			Timeout: "2s",
+		},
+		{Type: collector.MetricsSink, Address: ":9090"},
+		{
+			Name:  "history",
+			Type:  collector.StoreSink,
+			Path:  "/var/lib/baal",
+			Tiers: []collector.TierConfig{{Retention: "168h"}, {Resolution: "5m", Retention: "2160h"}},
+		},
+	}}
+	if !reflect.DeepEqual(want, config) {
+		msg := "want %+v, got %+v"
+		t.Fatalf(msg, want, config)
+	}
+	if got := config.Sinks[1].Label(1); got != "stdout 1" {
+		msg := "want label %q, got %q"
+		t.Fatalf(msg, "stdout 1", got)
+	}
+}
+
+func TestSinkError(t *testing.T) {
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+	tests := map[string]collector.SinkConfig{
+		"unknown type":   {Type: "kafka"},
+		"missing path":   {Type: collector.FileSink},
+		"unknown format": {Type: collector.StdoutSink, Format: "xml"},
+		"invalid url":    {Type: collector.HTTPSink, URL: "ftp://domain.com"},
+		"invalid time":   {Type: collector.HTTPSink, URL: "https://domain.com", Timeout: "soon"},
+		"negative size":  {Type: collector.StdoutSink, Buffer: -1},
+		"invalid age":    {Type: collector.FileSink, Path: "results.json", Rotation: collector.RotationConfig{Age: "old"}},
+		"negative keep":  {Type: collector.FileSink, Path: "results.json", Rotation: collector.RotationConfig{Keep: -1}},
+		"metrics":        {Type: collector.MetricsSink, Address: ":9090"},
+		"store path":     {Type: collector.StoreSink},
+		"store tier":     {Type: collector.StoreSink, Path: "store", Tiers: []collector.TierConfig{{Resolution: "5m"}}},
+	}
+	for name, config := range tests {
+		if _, err := config.Sink(logger); err == nil {
+			msg := "%s: want an error, got nothing"
+			t.Fatalf(msg, name)
+		}
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := monitor.Result{}
+		json.NewDecoder(r.Body).Decode(&result)
+		received <- fmt.Sprintf("%s %s %d", r.Header.Get("Authorization"), result.Location, result.Status)
+		if r.URL.Path == "/failing" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+	config := collector.SinkConfig{
+		Type:    collector.HTTPSink,
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	}
+	sut, err := config.Sink(logger)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	location, err := url.Parse("https://localhost")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if err := sut.Write(monitor.Result{Location: location, Status: 200}); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	want := "Bearer secret https://localhost 200"
+	if got := <-received; got != want {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, want, got)
+	}
+
+	config.URL = server.URL + "/failing"
+	if sut, err = config.Sink(logger); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if err := sut.Write(monitor.Result{Location: location}); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+}
@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+const defaultBuffer = 100
+
+type Sink interface {
+	Write(result monitor.Result) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(result monitor.Result) error
+
+func (f SinkFunc) Write(result monitor.Result) error {
+	return f(result)
+}
+
+type stopper interface {
+	Stop()
+}
+
+type reopener interface {
+	Reopen() error
+}
+
+type SinkStats struct {
+	Name     string
+	Written  int64
+	Dropped  int64
+	Errors   int64
+	Queued   int
+	Capacity int
+}
+
+type output struct {
+	written, dropped, errors int64
+
+	name   string
+	sink   Sink
+	buffer chan monitor.Result
+}
+
+func (o *output) run(wg *sync.WaitGroup, logger *log.Logger) {
+	defer wg.Done()
+	for result := range o.buffer {
+		if err := o.sink.Write(result); err != nil {
+			atomic.AddInt64(&o.errors, 1)
+			logger.Printf("sink %s: %v", o.name, err)
+			continue
+		}
+		atomic.AddInt64(&o.written, 1)
+	}
+}
+
+// Fanout hands every result to each of its sinks through a buffer of their
+// own. A result is dropped for a sink whose buffer is full, so a slow or
+// failing sink never holds back the others nor the workers upstream.
+type Fanout struct {
+	logger  *log.Logger
+	outputs []*output
+}
+
+// Add registers a sink before Run, with the default buffer when buffer is
+// not positive.
+func (f *Fanout) Add(name string, sink Sink, buffer int) {
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	output := output{name: name, sink: sink, buffer: make(chan monitor.Result, buffer)}
+	f.outputs = append(f.outputs, &output)
+}
+
+func (f *Fanout) Run(wg *sync.WaitGroup, results Results) {
+	defer wg.Done()
+
+	owg := new(sync.WaitGroup)
+	for _, output := range f.outputs {
+		owg.Add(1)
+		go output.run(owg, f.logger)
+	}
+	for result := range results {
+		for _, output := range f.outputs {
+			select {
+			case output.buffer <- result:
+			default:
+				atomic.AddInt64(&output.dropped, 1)
+			}
+		}
+	}
+	for _, output := range f.outputs {
+		close(output.buffer)
+	}
+	owg.Wait()
+}
+
+// Stop stops the sinks that need it, such as collectors closing their file.
+func (f *Fanout) Stop() {
+	for _, output := range f.outputs {
+		if stopper, ok := output.sink.(stopper); ok {
+			stopper.Stop()
+		}
+	}
+}
+
+// Reopen reopens the files of the sinks writing to one.
+func (f *Fanout) Reopen() {
+	for _, output := range f.outputs {
+		reopener, ok := output.sink.(reopener)
+		if !ok {
+			continue
+		}
+		if err := reopener.Reopen(); err != nil {
+			f.logger.Printf("sink %s: %v", output.name, err)
+		}
+	}
+}
+
+func (f *Fanout) Stats() []SinkStats {
+	stats := make([]SinkStats, 0, len(f.outputs))
+	for _, output := range f.outputs {
+		stats = append(stats, SinkStats{
+			Name:     output.name,
+			Written:  atomic.LoadInt64(&output.written),
+			Dropped:  atomic.LoadInt64(&output.dropped),
+			Errors:   atomic.LoadInt64(&output.errors),
+			Queued:   len(output.buffer),
+			Capacity: cap(output.buffer),
+		})
+	}
+	return stats
+}
+
+func NewFanout(logger *log.Logger) *Fanout {
+	fanout := Fanout{logger: logger}
+	return &fanout
+}
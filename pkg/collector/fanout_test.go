@@ -0,0 +1,108 @@
+package collector_test
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/ksahli/baal/pkg/collector"
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+type Sink struct {
+	lock    sync.Mutex
+	block   chan struct{}
+	fail    bool
+	stopped bool
+	results []monitor.Result
+}
+
+func (s *Sink) Write(result monitor.Result) error {
+	if s.block != nil {
+		<-s.block
+	}
+	if s.fail {
+		return errors.New("sink failure")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.results = append(s.results, result)
+	return nil
+}
+
+func (s *Sink) Stop() {
+	s.stopped = true
+}
+
+func TestFanout(t *testing.T) {
+	out := new(Out)
+	logger := log.New(out, " [collector] ", log.Ldate)
+	var (
+		fast    = &Sink{}
+		slow    = &Sink{block: make(chan struct{})}
+		failing = &Sink{fail: true}
+	)
+	sut := collector.NewFanout(logger)
+	sut.Add("fast", fast, 10)
+	sut.Add("slow", slow, 1)
+	sut.Add("failing", failing, 10)
+
+	location, err := url.Parse("https://localhost")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	results := make(chan monitor.Result)
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go sut.Run(wg, results)
+
+	// The slow sink takes the first result and blocks on it, buffers the
+	// second one and drops the others, without holding back the fast one.
+	for i := 0; i < 5; i++ {
+		results <- monitor.Result{Location: location, Status: i}
+	}
+	close(slow.block)
+	close(results)
+	wg.Wait()
+	sut.Stop()
+
+	want := map[string]collector.SinkStats{
+		"fast":    {Name: "fast", Written: 5, Capacity: 10},
+		"slow":    {Name: "slow", Written: 2, Dropped: 3, Capacity: 1},
+		"failing": {Name: "failing", Errors: 5, Capacity: 10},
+	}
+	stats := sut.Stats()
+	if len(stats) != len(want) {
+		msg := "want %d sinks, got %d"
+		t.Fatalf(msg, len(want), len(stats))
+	}
+	for _, got := range stats {
+		if got.Name == "slow" {
+			// The first result may be dropped as well when it reaches the
+			// buffer before the sink picks it up.
+			if got.Written+got.Dropped != 5 || got.Dropped < 3 {
+				msg := "slow: want 5 results written or dropped with at least 3 dropped, got %+v"
+				t.Fatalf(msg, got)
+			}
+			continue
+		}
+		if got != want[got.Name] {
+			msg := "want %+v, got %+v"
+			t.Fatalf(msg, want[got.Name], got)
+		}
+	}
+	if len(fast.results) != 5 {
+		msg := "want 5 results written by the fast sink, got %d"
+		t.Fatalf(msg, len(fast.results))
+	}
+	if len(out.messages) != 5 {
+		msg := "want 5 errors logged, got %d"
+		t.Fatalf(msg, len(out.messages))
+	}
+	if !fast.stopped || !slow.stopped || !failing.stopped {
+		t.Fatal("want every sink stopped")
+	}
+}
@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+type Encoder interface {
+	Encode(result monitor.Result) error
+}
+
+// Format creates the encoder of the results written to a file, header tells
+// whether the file is new and needs the column names first.
+type Format interface {
+	Encoder(writer io.Writer, header bool) Encoder
+}
+
+type JSON struct{}
+
+func (JSON) Encoder(writer io.Writer, header bool) Encoder {
+	return jsonEncoder{encoder: json.NewEncoder(writer)}
+}
+
+type jsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e jsonEncoder) Encode(result monitor.Result) error {
+	return e.encoder.Encode(&result)
+}
+
+// Delimited writes one row per result, nested fields flattened into the
+// columns below. Lists are joined with "; " and durations are in
+// milliseconds.
+type Delimited struct {
+	Comma rune
+}
+
+func (d Delimited) Encoder(writer io.Writer, header bool) Encoder {
+	encoder := delimitedEncoder{writer: csv.NewWriter(writer), header: header}
+	encoder.writer.Comma = d.Comma
+	return &encoder
+}
+
+var Columns = []string{
+	"location",
+	"method",
+	"status",
+	"reachable",
+	"time",
+	"timings.dns_ms",
+	"timings.connect_ms",
+	"timings.tls_ms",
+	"timings.first_byte_ms",
+	"timings.total_ms",
+	"failure",
+	"error",
+	"passed",
+	"violations",
+	"redirects",
+	"certificate.subject",
+	"certificate.issuer",
+	"certificate.names",
+	"certificate.not_after",
+	"certificate.verified",
+	"certificate.days_left",
+	"certificate.expiring",
+	"maintenance",
+}
+
+type delimitedEncoder struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (e *delimitedEncoder) Encode(result monitor.Result) error {
+	if e.header {
+		if err := e.writer.Write(Columns); err != nil {
+			return err
+		}
+		e.header = false
+	}
+	if err := e.writer.Write(Row(result)); err != nil {
+		return err
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// Row flattens a result in the order of Columns.
+func Row(result monitor.Result) []string {
+	location := ""
+	if result.Location != nil {
+		location = result.Location.String()
+	}
+	redirects := make([]string, 0, len(result.Redirects))
+	for _, hop := range result.Redirects {
+		redirects = append(redirects, fmt.Sprintf("%d %s -> %s", hop.Status, hop.URL, hop.Location))
+	}
+	row := []string{
+		location,
+		result.Method,
+		strconv.Itoa(result.Status),
+		strconv.FormatBool(result.Reachable),
+		timestamp(result.Time),
+		milliseconds(result.Timings.DNS),
+		milliseconds(result.Timings.Connect),
+		milliseconds(result.Timings.TLS),
+		milliseconds(result.Timings.FirstByte),
+		milliseconds(result.Timings.Total),
+		string(result.Failure),
+		result.Error,
+		strconv.FormatBool(result.Passed),
+		strings.Join(result.Violations, "; "),
+		strings.Join(redirects, "; "),
+	}
+	certificate := make([]string, 7)
+	if c := result.Certificate; c != nil {
+		certificate = []string{
+			c.Subject,
+			c.Issuer,
+			strings.Join(c.Names, "; "),
+			timestamp(c.NotAfter),
+			strconv.FormatBool(c.Verified),
+			strconv.Itoa(c.DaysLeft),
+			strconv.FormatBool(c.Expiring),
+		}
+	}
+	row = append(row, certificate...)
+	return append(row, strconv.FormatBool(result.Maintenance))
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+}
+
+var formats = map[string]Format{
+	"json":  JSON{},
+	"jsonl": JSON{},
+	"csv":   Delimited{Comma: ','},
+	"tsv":   Delimited{Comma: '\t'},
+}
+
+func Named(name string) (Format, error) {
+	format, ok := formats[strings.ToLower(name)]
+	if !ok {
+		err := fmt.Errorf("unknown format %q", name)
+		return nil, err
+	}
+	return format, nil
+}
+
+func Detect(path string) Format {
+	extension := strings.TrimPrefix(filepath.Ext(path), ".")
+	if format, err := Named(extension); err == nil {
+		return format
+	}
+	return JSON{}
+}
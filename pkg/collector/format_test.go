@@ -0,0 +1,176 @@
+package collector_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/collector"
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+type Buffer struct {
+	bytes.Buffer
+}
+
+func (b *Buffer) Close() error {
+	return nil
+}
+
+func TestRow(t *testing.T) {
+	location, err := url.Parse("https://localhost/health")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	result := monitor.Result{
+		Location:  location,
+		Method:    "HEAD",
+		Status:    503,
+		Reachable: true,
+		Time:      at,
+		Timings: monitor.Timings{
+			DNS:       time.Millisecond,
+			Connect:   2 * time.Millisecond,
+			TLS:       3 * time.Millisecond,
+			FirstByte: 4500 * time.Microsecond,
+			Total:     5 * time.Millisecond,
+		},
+		Violations: []string{"status 503", "body mismatch"},
+		Redirects:  []monitor.Hop{{URL: "http://localhost/", Status: 301, Location: "https://localhost/health"}},
+		Certificate: &monitor.Certificate{
+			Subject:  "localhost",
+			Issuer:   "test ca",
+			Names:    []string{"localhost", "127.0.0.1"},
+			NotAfter: at.Add(24 * time.Hour),
+			Verified: true,
+			DaysLeft: 1,
+			Expiring: true,
+		},
+		Maintenance: true,
+	}
+	want := []string{
+		"https://localhost/health", "HEAD", "503", "true", "2024-05-01T10:00:00Z",
+		"1", "2", "3", "4.5", "5",
+		"", "", "false",
+		"status 503; body mismatch",
+		"301 http://localhost/ -> https://localhost/health",
+		"localhost", "test ca", "localhost; 127.0.0.1", "2024-05-02T10:00:00Z", "true", "1", "true",
+		"true",
+	}
+	got := collector.Row(result)
+	if !reflect.DeepEqual(want, got) {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, want, got)
+	}
+	if len(got) != len(collector.Columns) {
+		msg := "want %d columns, got %d"
+		t.Fatalf(msg, len(collector.Columns), len(got))
+	}
+}
+
+func TestDelimited(t *testing.T) {
+	location, err := url.Parse("https://localhost")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	result := monitor.Result{Location: location, Failure: monitor.Timeout, Error: "deadline exceeded"}
+
+	for name, comma := range map[string]rune{"csv": ',', "tsv": '\t'} {
+		format, err := collector.Named(name)
+		if err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+		buffer := new(Buffer)
+		logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+		sut := collector.New(buffer, format, logger)
+		for i := 0; i < 2; i++ {
+			if err := sut.Write(result); err != nil {
+				msg := "unwanted error %v"
+				t.Fatalf(msg, err)
+			}
+		}
+		sut.Stop()
+
+		reader := csv.NewReader(strings.NewReader(buffer.String()))
+		reader.Comma = comma
+		records, err := reader.ReadAll()
+		if err != nil {
+			msg := "%s: unwanted error %v"
+			t.Fatalf(msg, name, err)
+		}
+		want := [][]string{collector.Columns, collector.Row(result), collector.Row(result)}
+		if !reflect.DeepEqual(want, records) {
+			msg := "%s: want %q, got %q"
+			t.Fatalf(msg, name, want, records)
+		}
+	}
+}
+
+func TestFileHeader(t *testing.T) {
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+	path := fmt.Sprintf("%s/results.csv", t.TempDir())
+	location, err := url.Parse("https://localhost")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	result := monitor.Result{Location: location}
+
+	for i := 0; i < 2; i++ {
+		sut, err := collector.File(path, nil, collector.Rotation{}, logger)
+		if err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+		if err := sut.Write(result); err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+		sut.Stop()
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	want := [][]string{collector.Columns, collector.Row(result), collector.Row(result)}
+	if !reflect.DeepEqual(want, records) {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, want, records)
+	}
+}
+
+func TestNamed(t *testing.T) {
+	if _, err := collector.Named("xml"); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+	tests := map[string]collector.Format{
+		"results.json": collector.JSON{},
+		"results.csv":  collector.Delimited{Comma: ','},
+		"results.TSV":  collector.Delimited{Comma: '\t'},
+		"results":      collector.JSON{},
+	}
+	for path, want := range tests {
+		if got := collector.Detect(path); !reflect.DeepEqual(want, got) {
+			msg := "%s: want %v, got %v"
+			t.Fatalf(msg, path, want, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+// HTTP posts every result as a JSON document to its URL.
+type HTTP struct {
+	URL    string
+	Header http.Header
+	Client *http.Client
+}
+
+func (h HTTP) Write(result monitor.Result) error {
+	body, err := json.Marshal(&result)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range h.Header {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := h.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		err := fmt.Errorf("%s replied %s", h.URL, response.Status)
+		return err
+	}
+	return nil
+}
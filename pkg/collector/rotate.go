@@ -0,0 +1,281 @@
+package collector
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const stamp = "20060102T150405.000000000"
+
+// Rotation starts a new results file once the current one reaches Size
+// bytes or is older than Interval, zero disabling either. Rotated files are
+// renamed with their rotation time, gzipped when Compress is set, and only
+// the Keep most recent ones younger than Age are kept, zero keeping them all.
+type Rotation struct {
+	Size     int64
+	Interval time.Duration
+	Compress bool
+	Keep     int
+	Age      time.Duration
+}
+
+func (r Rotation) Validate() error {
+	switch {
+	case r.Size < 0:
+		return fmt.Errorf("negative rotation size %d", r.Size)
+	case r.Interval < 0:
+		return fmt.Errorf("negative rotation interval %s", r.Interval)
+	case r.Keep < 0:
+		return fmt.Errorf("negative rotation keep %d", r.Keep)
+	case r.Age < 0:
+		return fmt.Errorf("negative rotation age %s", r.Age)
+	}
+	return nil
+}
+
+// Rotator is the results file of a collector. It does not lock its writes,
+// the collector serialises them and its rotations.
+type Rotator struct {
+	path     string
+	rotation Rotation
+	stamper  func() time.Time
+	logger   *log.Logger
+
+	// file is nil when the path failed to open after a rotation or a
+	// reopen, the next rotation opens it again.
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	// rotated files are compressed and pruned in the background, one
+	// rotation after the other.
+	lock *sync.Mutex
+	wg   *sync.WaitGroup
+}
+
+func (r *Rotator) Write(p []byte) (int, error) {
+	if r.file == nil {
+		err := fmt.Errorf("%s is not open", r.path)
+		return 0, err
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *Rotator) Sync() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Sync()
+}
+
+func (r *Rotator) Close() error {
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.wg.Wait()
+	return err
+}
+
+// Empty reports whether nothing was written to the current file yet.
+func (r *Rotator) Empty() bool {
+	return r.size == 0
+}
+
+func (r *Rotator) due(now time.Time) bool {
+	switch {
+	case r.size == 0:
+		return false
+	case r.rotation.Size > 0 && r.size >= r.rotation.Size:
+		return true
+	case r.rotation.Interval > 0 && now.Sub(r.opened) >= r.rotation.Interval:
+		return true
+	}
+	return false
+}
+
+// Rotate moves the current file aside and opens a new one when it is due,
+// and reports whether a new file was opened, either by the rotation or
+// because the previous one failed to open.
+func (r *Rotator) Rotate() (bool, error) {
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	now := r.stamper()
+	if !r.due(now) {
+		return false, nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		return false, err
+	}
+	rotated := r.rotated(now)
+	if err := os.Rename(r.path, rotated); err != nil {
+		if reopen := r.open(); reopen != nil {
+			return false, reopen
+		}
+		return false, err
+	}
+
+	r.wg.Add(1)
+	go r.archive(rotated)
+	if err := r.open(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rotated names the file rotated at now, moving the time forward when a file
+// rotated in the same instant still exists.
+func (r *Rotator) rotated(now time.Time) string {
+	extension := filepath.Ext(r.path)
+	for {
+		rotated := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, extension), now.Format(stamp), extension)
+		_, err := os.Stat(rotated)
+		_, compressed := os.Stat(rotated + ".gz")
+		if os.IsNotExist(err) && os.IsNotExist(compressed) {
+			return rotated
+		}
+		now = now.Add(time.Nanosecond)
+	}
+}
+
+// Reopen closes the file and opens its path again, so a file moved away by
+// another tool such as logrotate is replaced.
+func (r *Rotator) Reopen() error {
+	if r.file != nil {
+		err := r.file.Close()
+		r.file = nil
+		if err != nil {
+			return err
+		}
+	}
+	return r.open()
+}
+
+func (r *Rotator) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size, r.opened = file, info.Size(), r.stamper()
+	return nil
+}
+
+func (r *Rotator) archive(rotated string) {
+	defer r.wg.Done()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.rotation.Compress {
+		if err := compress(rotated); err != nil {
+			r.logger.Printf("collector error: compress %s: %v", rotated, err)
+			return
+		}
+	}
+	r.prune()
+}
+
+// prune removes the rotated files beyond the ones to keep, oldest first.
+func (r *Rotator) prune() {
+	if r.rotation.Keep == 0 && r.rotation.Age == 0 {
+		return
+	}
+	var (
+		extension = filepath.Ext(r.path)
+		prefix    = strings.TrimSuffix(r.path, extension) + "-"
+		rotated   = []string{}
+	)
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		r.logger.Printf("collector error: prune %s: %v", r.path, err)
+		return
+	}
+	for _, match := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ".gz")
+		if _, err := time.Parse(stamp, strings.TrimSuffix(name, extension)); err == nil {
+			rotated = append(rotated, match)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+
+	now := r.stamper()
+	for index, path := range rotated {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		expired := r.rotation.Age > 0 && now.Sub(info.ModTime()) > r.rotation.Age
+		if (r.rotation.Keep > 0 && index >= r.rotation.Keep) || expired {
+			if err := os.Remove(path); err != nil {
+				r.logger.Printf("collector error: prune %s: %v", path, err)
+			}
+		}
+	}
+}
+
+func compress(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		target.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		target.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := target.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+func NewRotator(path string, rotation Rotation, stamper func() time.Time, logger *log.Logger) (*Rotator, error) {
+	if err := rotation.Validate(); err != nil {
+		return nil, err
+	}
+	rotator := Rotator{
+		path:     path,
+		rotation: rotation,
+		stamper:  stamper,
+		logger:   logger,
+		lock:     new(sync.Mutex),
+		wg:       new(sync.WaitGroup),
+	}
+	if err := rotator.open(); err != nil {
+		return nil, err
+	}
+	return &rotator, nil
+}
@@ -0,0 +1,342 @@
+package collector_test
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/collector"
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+func records(t *testing.T, reader io.Reader) [][]string {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	return records
+}
+
+func rotated(t *testing.T, directory string) []string {
+	paths, err := filepath.Glob(directory + "/results-*")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestRotateSize(t *testing.T) {
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+	directory := t.TempDir()
+	path := fmt.Sprintf("%s/results.csv", directory)
+	location, err := url.Parse("https://localhost")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	sut, err := collector.File(path, nil, collector.Rotation{Size: 1, Keep: 2}, logger)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := sut.Write(monitor.Result{Location: location, Status: i}); err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+	}
+	sut.Stop()
+
+	paths := rotated(t, directory)
+	if len(paths) != 2 {
+		msg := "want 2 rotated files kept, got %v"
+		t.Fatalf(msg, paths)
+	}
+	for index, path := range append(paths, path) {
+		file, err := os.Open(path)
+		if err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+		want := [][]string{collector.Columns, collector.Row(monitor.Result{Location: location, Status: index + 1})}
+		if got := records(t, file); !reflect.DeepEqual(want, got) {
+			msg := "%s: want %q, got %q"
+			t.Fatalf(msg, path, want, got)
+		}
+		file.Close()
+	}
+}
+
+func TestRotateCompress(t *testing.T) {
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+	directory := t.TempDir()
+	path := fmt.Sprintf("%s/results.csv", directory)
+	location, err := url.Parse("https://localhost")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	sut, err := collector.File(path, nil, collector.Rotation{Size: 1, Compress: true}, logger)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := sut.Write(monitor.Result{Location: location, Status: i}); err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+	}
+	sut.Stop()
+
+	paths := rotated(t, directory)
+	if len(paths) != 1 || filepath.Ext(paths[0]) != ".gz" {
+		msg := "want 1 gzipped rotated file, got %v"
+		t.Fatalf(msg, paths)
+	}
+	file, err := os.Open(paths[0])
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	want := [][]string{collector.Columns, collector.Row(monitor.Result{Location: location})}
+	if got := records(t, reader); !reflect.DeepEqual(want, got) {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestRotateInterval(t *testing.T) {
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+	directory := t.TempDir()
+	path := fmt.Sprintf("%s/results.json", directory)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	stamper := func() time.Time { return now }
+
+	// An expired rotated file is removed with the next rotation, unrelated
+	// files are left alone.
+	expired := fmt.Sprintf("%s/results-20240101T000000.000000000.json.gz", directory)
+	unrelated := fmt.Sprintf("%s/results-backup.json", directory)
+	for _, path := range []string{expired, unrelated} {
+		if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+		old := now.Add(-30 * 24 * time.Hour)
+		if err := os.Chtimes(path, old, old); err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+	}
+
+	sut, err := collector.NewRotator(path, collector.Rotation{Interval: time.Hour, Age: 7 * 24 * time.Hour}, stamper, logger)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if _, err := sut.Write([]byte("{}\n")); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if rotated, err := sut.Rotate(); err != nil || rotated {
+		msg := "want no rotation before the interval, got %t and %v"
+		t.Fatalf(msg, rotated, err)
+	}
+	now = now.Add(time.Hour)
+	if rotated, err := sut.Rotate(); err != nil || !rotated {
+		msg := "want a rotation after the interval, got %t and %v"
+		t.Fatalf(msg, rotated, err)
+	}
+	if !sut.Empty() {
+		t.Fatal("want an empty file after the rotation")
+	}
+	if err := sut.Close(); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	want := []string{
+		fmt.Sprintf("%s/results-20240501T110000.000000000.json", directory),
+		unrelated,
+	}
+	if got := rotated(t, directory); !reflect.DeepEqual(want, got) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestRotationError(t *testing.T) {
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+	path := fmt.Sprintf("%s/results.json", t.TempDir())
+	for _, rotation := range []collector.Rotation{{Size: -1}, {Interval: -time.Second}, {Keep: -1}, {Age: -time.Second}} {
+		if _, err := collector.NewRotator(path, rotation, time.Now, logger); err == nil {
+			msg := "%+v: want an error, got nothing"
+			t.Fatalf(msg, rotation)
+		}
+	}
+}
+
+func TestReopen(t *testing.T) {
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+	directory := t.TempDir()
+	path := fmt.Sprintf("%s/results.csv", directory)
+	location, err := url.Parse("https://localhost")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	result := monitor.Result{Location: location}
+
+	sut, err := collector.File(path, nil, collector.Rotation{}, logger)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	defer sut.Stop()
+	if err := sut.Write(result); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	moved := fmt.Sprintf("%s/results.csv.1", directory)
+	if err := os.Rename(path, moved); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if err := sut.Reopen(); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if err := sut.Write(result); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	want := [][]string{collector.Columns, collector.Row(result)}
+	for _, path := range []string{moved, path} {
+		file, err := os.Open(path)
+		if err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+		if got := records(t, file); !reflect.DeepEqual(want, got) {
+			msg := "%s: want %q, got %q"
+			t.Fatalf(msg, path, want, got)
+		}
+		file.Close()
+	}
+}
+
+func TestReopenError(t *testing.T) {
+	logger := log.New(os.Stderr, " [collector] ", log.Ldate)
+	directory := t.TempDir()
+	path := fmt.Sprintf("%s/results.csv", directory)
+	location, err := url.Parse("https://localhost")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	result := monitor.Result{Location: location}
+
+	sut, err := collector.File(path, nil, collector.Rotation{}, logger)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	defer sut.Stop()
+
+	// The path can not be opened for a while, the writes fail until it can
+	// be again.
+	if err := os.Rename(path, path+".1"); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if err := os.Mkdir(path, 0755); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if err := sut.Reopen(); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+	if err := sut.Write(result); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+	if err := os.Remove(path); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if err := sut.Write(result); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	defer file.Close()
+	want := [][]string{collector.Columns, collector.Row(result)}
+	if got := records(t, file); !reflect.DeepEqual(want, got) {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestRotatePruneError(t *testing.T) {
+	out := new(Out)
+	logger := log.New(out, " [collector] ", log.Ldate)
+	directory := t.TempDir()
+	path := fmt.Sprintf("%s/results.csv", directory)
+	location, err := url.Parse("https://localhost")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	// A directory named like a rotated file can not be removed.
+	stale := fmt.Sprintf("%s/results-20200101T000000.000000000.csv", directory)
+	if err := os.MkdirAll(stale+"/content", 0755); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	sut, err := collector.File(path, nil, collector.Rotation{Size: 1, Keep: 1}, logger)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := sut.Write(monitor.Result{Location: location}); err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+	}
+	sut.Stop()
+
+	if len(out.messages) != 1 || !strings.Contains(out.messages[0], stale) {
+		msg := "want the failure to remove %s logged, got %q"
+		t.Fatalf(msg, stale, out.messages)
+	}
+}
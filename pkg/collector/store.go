@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"log"
+	"time"
+
+	"github.com/ksahli/baal/pkg/store"
+)
+
+type stored struct {
+	*store.Store
+	logger *log.Logger
+}
+
+func (s stored) Stop() {
+	if err := s.Close(); err != nil {
+		s.logger.Print(err)
+	}
+}
+
+// Store opens a sink keeping the results in the store in dir, with the
+// default tiers when tiers is empty.
+func Store(dir string, tiers []store.Tier, logger *log.Logger) (Sink, error) {
+	results, err := store.New(dir, tiers, time.Now)
+	if err != nil {
+		return nil, err
+	}
+	return stored{Store: results, logger: logger}, nil
+}
@@ -0,0 +1,311 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type series struct {
+	target, method string
+
+	up, passed float64
+	status     float64
+
+	// certificate is the last one seen, a probe failing before the
+	// handshake does not make its expiry unknown.
+	certificate *monitor.Certificate
+
+	buckets []uint64
+	count   uint64
+	sum     float64
+
+	outcomes map[string]uint64
+}
+
+type family struct {
+	name, help, kind string
+	label            string
+	values           func() map[string]float64
+}
+
+// Exporter keeps the latest state of every target from the results it
+// receives and exposes it, with its registered gauges, in the Prometheus
+// text format.
+type Exporter struct {
+	lock     *sync.Mutex
+	buckets  []float64
+	targets  map[string]*series
+	families []family
+
+	// tracked returns the state and incidents of the jobs, nil until
+	// Track is called.
+	tracked func() []tracker.TargetStats
+
+	// keys are the jobs still defined once Update was called, the results of
+	// the others still in flight are ignored.
+	keys map[string]bool
+}
+
+func (e *Exporter) Record(result monitor.Result) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	key := result.Key()
+	if e.keys != nil && !e.keys[key] {
+		return
+	}
+	current, ok := e.targets[key]
+	if !ok {
+		current = &series{
+			target:   result.Location.String(),
+			method:   result.Method,
+			buckets:  make([]uint64, len(e.buckets)),
+			outcomes: map[string]uint64{},
+		}
+		if current.method == "" {
+			current.method = http.MethodGet
+		}
+		e.targets[key] = current
+	}
+	current.up, current.passed = boolean(result.Reachable), boolean(result.Passed)
+	current.status = float64(result.Status)
+	if result.Certificate != nil {
+		current.certificate = result.Certificate
+	}
+
+	latency := result.Timings.Total.Seconds()
+	for index, bound := range e.buckets {
+		if latency <= bound {
+			current.buckets[index]++
+		}
+	}
+	current.count++
+	current.sum += latency
+	current.outcomes[outcome(result)]++
+}
+
+// Update keeps the series of the given jobs, keyed as monitor.Key does, and
+// drops the ones of the jobs removed from the definitions.
+func (e *Exporter) Update(keys []string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.keys = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		e.keys[key] = true
+	}
+	for key := range e.targets {
+		if !e.keys[key] {
+			delete(e.targets, key)
+		}
+	}
+}
+
+func (e *Exporter) Run(wg *sync.WaitGroup, results <-chan monitor.Result) {
+	defer wg.Done()
+	for result := range results {
+		e.Record(result)
+	}
+}
+
+// Track registers the source of the state and incidents of the jobs, read
+// each time the metrics are written.
+func (e *Exporter) Track(tracked func() []tracker.TargetStats) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.tracked = tracked
+}
+
+// Gauge registers an internal metric read each time the metrics are
+// written, such as the depth of a queue.
+func (e *Exporter) Gauge(name, help string, value func() float64) {
+	e.Family(name, help, "gauge", "", func() map[string]float64 {
+		return map[string]float64{"": value()}
+	})
+}
+
+// Counter registers an internal metric that only grows, such as the jobs
+// dropped by the pool.
+func (e *Exporter) Counter(name, help string, value func() float64) {
+	e.Family(name, help, "counter", "", func() map[string]float64 {
+		return map[string]float64{"": value()}
+	})
+}
+
+// Family registers an internal metric of the given kind with one sample per
+// value of its label, such as the results dropped by each sink.
+func (e *Exporter) Family(name, help, kind, label string, values func() map[string]float64) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.families = append(e.families, family{name: name, help: help, kind: kind, label: label, values: values})
+}
+
+func (e *Exporter) Write(writer io.Writer) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	w := bufio.NewWriter(writer)
+	keys := make([]string, 0, len(e.targets))
+	for key := range e.targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	header(w, "baal_target_up", "gauge", "Whether the last probe of the target reached it.")
+	for _, key := range keys {
+		sample(w, "baal_target_up", e.targets[key].labels(), e.targets[key].up)
+	}
+	header(w, "baal_target_passed", "gauge", "Whether the last probe of the target passed its assertions.")
+	for _, key := range keys {
+		sample(w, "baal_target_passed", e.targets[key].labels(), e.targets[key].passed)
+	}
+	header(w, "baal_target_status_code", "gauge", "HTTP status code of the last probe of the target, 0 when unreachable.")
+	for _, key := range keys {
+		sample(w, "baal_target_status_code", e.targets[key].labels(), e.targets[key].status)
+	}
+	header(w, "baal_certificate_days_left", "gauge", "Days left before the certificate of the target expires.")
+	for _, key := range keys {
+		if certificate := e.targets[key].certificate; certificate != nil {
+			sample(w, "baal_certificate_days_left", e.targets[key].labels(), float64(certificate.DaysLeft))
+		}
+	}
+
+	header(w, "baal_probe_duration_seconds", "histogram", "Duration of the probes of the target.")
+	for _, key := range keys {
+		current := e.targets[key]
+		for index, bound := range e.buckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			sample(w, "baal_probe_duration_seconds_bucket", current.labels("le", le), float64(current.buckets[index]))
+		}
+		sample(w, "baal_probe_duration_seconds_bucket", current.labels("le", "+Inf"), float64(current.count))
+		sample(w, "baal_probe_duration_seconds_sum", current.labels(), current.sum)
+		sample(w, "baal_probe_duration_seconds_count", current.labels(), float64(current.count))
+	}
+
+	header(w, "baal_probes_total", "counter", "Probes of the target by outcome: passed, failed or the failure class.")
+	for _, key := range keys {
+		outcomes := make([]string, 0, len(e.targets[key].outcomes))
+		for outcome := range e.targets[key].outcomes {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Strings(outcomes)
+		for _, outcome := range outcomes {
+			sample(w, "baal_probes_total", e.targets[key].labels("outcome", outcome), float64(e.targets[key].outcomes[outcome]))
+		}
+	}
+
+	if e.tracked != nil {
+		incidents(w, e.tracked())
+	}
+
+	for _, family := range e.families {
+		header(w, family.name, family.kind, family.help)
+		values := family.values()
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if family.label == "" {
+				sample(w, family.name, "", values[key])
+				continue
+			}
+			sample(w, family.name, labels(family.label, key), values[key])
+		}
+	}
+	return w.Flush()
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	e.Write(w)
+}
+
+// incidents writes the state of the jobs, one sample per state set to 1 for
+// the current one, and the incidents they had.
+func incidents(w io.Writer, stats []tracker.TargetStats) {
+	header(w, "baal_target_state", "gauge", "State of the target, 1 for the current one.")
+	for _, stat := range stats {
+		for _, state := range []tracker.State{tracker.Up, tracker.Degraded, tracker.Down} {
+			value := boolean(stat.State == state)
+			sample(w, "baal_target_state", labels("target", stat.Target, "method", stat.Method, "state", string(state)), value)
+		}
+	}
+	header(w, "baal_incidents_total", "counter", "Incidents of the target, a degraded or down state until it is up again.")
+	for _, stat := range stats {
+		sample(w, "baal_incidents_total", labels("target", stat.Target, "method", stat.Method), float64(stat.Incidents))
+	}
+	header(w, "baal_incident_start_seconds", "gauge", "Start of the incident still open of the target, in seconds since the epoch.")
+	for _, stat := range stats {
+		if !stat.Since.IsZero() {
+			sample(w, "baal_incident_start_seconds", labels("target", stat.Target, "method", stat.Method), float64(stat.Since.Unix()))
+		}
+	}
+}
+
+// labels are the ones of the target and method of the series, followed by
+// the given pairs.
+func (s *series) labels(pairs ...string) string {
+	return labels(append([]string{"target", s.target, "method", s.method}, pairs...)...)
+}
+
+func header(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sample(w io.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", pairs[i], escaper.Replace(pairs[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func outcome(result monitor.Result) string {
+	switch {
+	case result.Passed:
+		return "passed"
+	case result.Failure != "":
+		return string(result.Failure)
+	}
+	return "failed"
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func New(buckets []float64) *Exporter {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	exporter := Exporter{
+		lock:    new(sync.Mutex),
+		buckets: buckets,
+		targets: map[string]*series{},
+	}
+	return &exporter
+}
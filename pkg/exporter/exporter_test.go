@@ -0,0 +1,184 @@
+package exporter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/exporter"
+	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+func TestRun(t *testing.T) {
+	location, err := url.Parse("https://localhost/health")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	results := make(chan monitor.Result, 4)
+	results <- monitor.Result{
+		Location:    location,
+		Status:      200,
+		Reachable:   true,
+		Passed:      true,
+		Timings:     monitor.Timings{Total: 20 * time.Millisecond},
+		Certificate: &monitor.Certificate{DaysLeft: 30},
+	}
+	results <- monitor.Result{
+		Location:  location,
+		Status:    500,
+		Reachable: true,
+		Timings:   monitor.Timings{Total: 200 * time.Millisecond},
+	}
+	results <- monitor.Result{
+		Location: location,
+		Failure:  monitor.Timeout,
+		Timings:  monitor.Timings{Total: 30 * time.Second},
+	}
+	// Another method on the same location has series of its own.
+	results <- monitor.Result{
+		Location:  location,
+		Method:    http.MethodHead,
+		Status:    200,
+		Reachable: true,
+		Passed:    true,
+	}
+	close(results)
+
+	sut := exporter.New([]float64{.1, 1})
+	sut.Gauge("baal_queue_depth", "Jobs waiting.", func() float64 { return 7 })
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	sut.Run(wg, results)
+
+	builder := new(strings.Builder)
+	if err := sut.Write(builder); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	got := builder.String()
+
+	target := `target="https://localhost/health",method="GET"`
+	for _, want := range []string{
+		"# TYPE baal_target_up gauge",
+		"baal_target_up{" + target + "} 0",
+		`baal_target_up{target="https://localhost/health",method="HEAD"} 1`,
+		"baal_target_passed{" + target + "} 0",
+		"baal_target_status_code{" + target + "} 0",
+		"baal_certificate_days_left{" + target + "} 30",
+		"# TYPE baal_probe_duration_seconds histogram",
+		"baal_probe_duration_seconds_bucket{" + target + `,le="0.1"} 1`,
+		"baal_probe_duration_seconds_bucket{" + target + `,le="1"} 2`,
+		"baal_probe_duration_seconds_bucket{" + target + `,le="+Inf"} 3`,
+		"baal_probe_duration_seconds_sum{" + target + "} 30.22",
+		"baal_probe_duration_seconds_count{" + target + "} 3",
+		"# TYPE baal_probes_total counter",
+		"baal_probes_total{" + target + `,outcome="failed"} 1`,
+		"baal_probes_total{" + target + `,outcome="passed"} 1`,
+		"baal_probes_total{" + target + `,outcome="timeout"} 1`,
+		"# TYPE baal_queue_depth gauge",
+		"baal_queue_depth 7",
+	} {
+		if !strings.Contains(got, want+"\n") {
+			msg := "want %q in metrics, got %s"
+			t.Fatalf(msg, want, got)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	sut := exporter.New(nil)
+	recorder := httptest.NewRecorder()
+	sut.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		msg := "want the prometheus text content type, got %q"
+		t.Fatalf(msg, got)
+	}
+	if got := recorder.Body.String(); !strings.Contains(got, "# TYPE baal_target_up gauge") {
+		msg := "want the metric families, got %q"
+		t.Fatalf(msg, got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	sut := exporter.New(nil)
+	kept, err := url.Parse("https://domain-1.com")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	removed, err := url.Parse("https://domain-2.com")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	sut.Record(monitor.Result{Location: kept, Reachable: true})
+	sut.Record(monitor.Result{Location: removed, Reachable: true})
+
+	sut.Update([]string{"GET https://domain-1.com"})
+	// A result of the removed target still in flight does not bring its
+	// series back.
+	sut.Record(monitor.Result{Location: removed, Reachable: true})
+
+	builder := new(strings.Builder)
+	if err := sut.Write(builder); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	got := builder.String()
+	if !strings.Contains(got, `baal_target_up{target="https://domain-1.com",method="GET"} 1`) {
+		msg := "want the series of the kept target, got %s"
+		t.Fatalf(msg, got)
+	}
+	if strings.Contains(got, "domain-2.com") {
+		msg := "want no series of the removed target, got %s"
+		t.Fatalf(msg, got)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	sut := exporter.New(nil)
+	since := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	sut.Track(func() []tracker.TargetStats {
+		return []tracker.TargetStats{
+			{Key: "GET https://domain-1.com", Target: "https://domain-1.com", Method: "GET", State: tracker.Up, Incidents: 1},
+			{Key: "HEAD https://domain-2.com", Target: "https://domain-2.com", Method: "HEAD", State: tracker.Down, Incidents: 2, Since: since},
+		}
+	})
+
+	builder := new(strings.Builder)
+	if err := sut.Write(builder); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	got := builder.String()
+
+	first, second := `target="https://domain-1.com",method="GET"`, `target="https://domain-2.com",method="HEAD"`
+	for _, want := range []string{
+		"# TYPE baal_target_state gauge",
+		"baal_target_state{" + first + `,state="up"} 1`,
+		"baal_target_state{" + first + `,state="down"} 0`,
+		"baal_target_state{" + second + `,state="up"} 0`,
+		"baal_target_state{" + second + `,state="down"} 1`,
+		"# TYPE baal_incidents_total counter",
+		"baal_incidents_total{" + first + "} 1",
+		"baal_incidents_total{" + second + "} 2",
+		"baal_incident_start_seconds{" + second + "} 1.6409952e+09",
+	} {
+		if !strings.Contains(got, want+"\n") {
+			msg := "want %q in metrics, got %s"
+			t.Fatalf(msg, want, got)
+		}
+	}
+	if strings.Contains(got, "baal_incident_start_seconds{"+first) {
+		msg := "want no incident start of the target up, got %s"
+		t.Fatalf(msg, got)
+	}
+}
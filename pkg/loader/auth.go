@@ -0,0 +1,71 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+type Secret struct {
+	Env  string `json:"env"`
+	File string `json:"file"`
+}
+
+func (s Secret) check() error {
+	switch {
+	case s.Env != "" && s.File != "":
+		return errors.New("secret must be read from either env or file")
+	case s.Env == "" && s.File == "":
+		return errors.New("secret has no env or file")
+	}
+	return nil
+}
+
+func (s Secret) Resolve() (string, error) {
+	if err := s.check(); err != nil {
+		return "", err
+	}
+	if s.Env != "" {
+		value, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		return value, nil
+	}
+	content, err := os.ReadFile(s.File)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+type Auth struct {
+	Type     string `json:"type"`
+	Username string `json:"username"`
+	Password Secret `json:"password"`
+	Token    Secret `json:"token"`
+}
+
+func (a *Auth) Credentials() (monitor.Credentials, error) {
+	if a == nil {
+		return nil, nil
+	}
+	switch strings.ToLower(a.Type) {
+	case "basic":
+		password, err := a.Password.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("basic auth password: %w", err)
+		}
+		return monitor.Basic{Username: a.Username, Password: password}, nil
+	case "bearer":
+		token, err := a.Token.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("bearer auth token: %w", err)
+		}
+		return monitor.Bearer(token), nil
+	}
+	return nil, fmt.Errorf("unknown auth type %q", a.Type)
+}
@@ -0,0 +1,119 @@
+package loader_test
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ksahli/baal/pkg/loader"
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("BAAL_TEST_SECRET", "from env")
+	path := fmt.Sprintf("%s/secret", t.TempDir())
+	if err := os.WriteFile(path, []byte("from file\n"), 0600); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	tests := map[string]struct {
+		secret loader.Secret
+		want   string
+	}{
+		"env": {
+			secret: loader.Secret{Env: "BAAL_TEST_SECRET"},
+			want:   "from env",
+		},
+		"file": {
+			secret: loader.Secret{File: path},
+			want:   "from file",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := test.secret.Resolve()
+			if err != nil {
+				msg := "unwanted error %v"
+				t.Fatalf(msg, err)
+			}
+			if got != test.want {
+				msg := "want %q, got %q"
+				t.Fatalf(msg, test.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveError(t *testing.T) {
+	tests := map[string]loader.Secret{
+		"nothing":      {},
+		"both":         {Env: "HOME", File: "/etc/hostname"},
+		"missing env":  {Env: "BAAL_TEST_MISSING"},
+		"missing file": {File: fmt.Sprintf("%s/missing", t.TempDir())},
+	}
+	for name, secret := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := secret.Resolve(); err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+		})
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	t.Setenv("BAAL_TEST_SECRET", "secret")
+	tests := map[string]struct {
+		auth *loader.Auth
+		want monitor.Credentials
+	}{
+		"nothing": {
+			auth: nil,
+			want: nil,
+		},
+		"basic": {
+			auth: &loader.Auth{
+				Type:     "basic",
+				Username: "user",
+				Password: loader.Secret{Env: "BAAL_TEST_SECRET"},
+			},
+			want: monitor.Basic{Username: "user", Password: "secret"},
+		},
+		"bearer": {
+			auth: &loader.Auth{
+				Type:  "Bearer",
+				Token: loader.Secret{Env: "BAAL_TEST_SECRET"},
+			},
+			want: monitor.Bearer("secret"),
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := test.auth.Credentials()
+			if err != nil {
+				msg := "unwanted error %v"
+				t.Fatalf(msg, err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				msg := "want %v, got %v"
+				t.Fatalf(msg, test.want, got)
+			}
+		})
+	}
+}
+
+func TestCredentialsError(t *testing.T) {
+	tests := map[string]*loader.Auth{
+		"type":     {Type: "digest"},
+		"password": {Type: "basic", Username: "user"},
+		"token":    {Type: "bearer", Token: loader.Secret{Env: "BAAL_TEST_MISSING"}},
+	}
+	for name, auth := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := auth.Credentials(); err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+		})
+	}
+}
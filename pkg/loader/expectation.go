@@ -0,0 +1,138 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+type Expectation struct {
+	Status  Statuses          `json:"status"`
+	Body    BodyExpectation   `json:"body"`
+	Headers map[string]string `json:"headers"`
+}
+
+// Statuses are written as strings, such as "200", "2xx" or "200-299", or as
+// bare codes, in a list or alone. Other values are kept as written for the
+// validation to report them.
+type Statuses []string
+
+func (s *Statuses) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	elements := []json.RawMessage{data}
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return err
+		}
+	}
+	statuses := make(Statuses, 0, len(elements))
+	for _, element := range elements {
+		var spec string
+		if err := json.Unmarshal(element, &spec); err != nil {
+			spec = string(bytes.TrimSpace(element))
+		}
+		statuses = append(statuses, spec)
+	}
+	*s = statuses
+	return nil
+}
+
+type BodyExpectation struct {
+	Contains    []string `json:"contains"`
+	NotContains []string `json:"not_contains"`
+	Matches     []string `json:"matches"`
+	NotMatches  []string `json:"not_matches"`
+}
+
+func (e Expectation) Assertions() ([]monitor.Assertion, error) {
+	assertions := []monitor.Assertion{}
+
+	if len(e.Status) > 0 {
+		status := make(monitor.Status, 0, len(e.Status))
+		for _, spec := range e.Status {
+			r, err := statusRange(spec)
+			if err != nil {
+				return nil, err
+			}
+			status = append(status, r)
+		}
+		assertions = append(assertions, status)
+	}
+
+	for _, substring := range e.Body.Contains {
+		assertions = append(assertions, monitor.BodyContains(substring))
+	}
+	for _, substring := range e.Body.NotContains {
+		assertions = append(assertions, monitor.BodyExcludes(substring))
+	}
+	for _, expression := range e.Body.Matches {
+		compiled, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, monitor.BodyMatches{Regexp: compiled})
+	}
+	for _, expression := range e.Body.NotMatches {
+		compiled, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, monitor.BodyRejects{Regexp: compiled})
+	}
+
+	names := make([]string, 0, len(e.Headers))
+	for name := range e.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header := monitor.Header{Name: name, Value: e.Headers[name]}
+		assertions = append(assertions, header)
+	}
+
+	if len(assertions) == 0 {
+		return nil, nil
+	}
+	return assertions, nil
+}
+
+// statusRange accepts a single code ("200"), a class ("2xx") or an
+// inclusive range ("200-299").
+func statusRange(spec string) (monitor.StatusRange, error) {
+	spec = strings.TrimSpace(spec)
+	invalid := fmt.Errorf("invalid status %q", spec)
+
+	if len(spec) == 3 && strings.HasSuffix(strings.ToLower(spec), "xx") {
+		class, err := strconv.Atoi(spec[:1])
+		if err != nil || class < 1 || class > 5 {
+			return monitor.StatusRange{}, invalid
+		}
+		return monitor.StatusRange{Min: class * 100, Max: class*100 + 99}, nil
+	}
+
+	bounds := strings.SplitN(spec, "-", 2)
+	min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return monitor.StatusRange{}, invalid
+	}
+	max := min
+	if len(bounds) == 2 {
+		max, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if err != nil {
+			return monitor.StatusRange{}, invalid
+		}
+	}
+	if min < 100 || max > 599 || min > max {
+		return monitor.StatusRange{}, invalid
+	}
+	return monitor.StatusRange{Min: min, Max: max}, nil
+}
@@ -0,0 +1,83 @@
+package loader_test
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/ksahli/baal/pkg/loader"
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+func TestAssertions(t *testing.T) {
+	expectation := loader.Expectation{
+		Status: []string{"200", "3xx", "401-403"},
+		Body: loader.BodyExpectation{
+			Contains:    []string{"ok"},
+			NotContains: []string{"error"},
+			Matches:     []string{`"version":\s*"\d+"`},
+			NotMatches:  []string{`(?i)exception`},
+		},
+		Headers: map[string]string{
+			"X-Version":    "",
+			"Content-Type": "application/json",
+		},
+	}
+	got, err := expectation.Assertions()
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	want := []monitor.Assertion{
+		monitor.Status{
+			{Min: 200, Max: 200},
+			{Min: 300, Max: 399},
+			{Min: 401, Max: 403},
+		},
+		monitor.BodyContains("ok"),
+		monitor.BodyExcludes("error"),
+		monitor.BodyMatches{Regexp: regexp.MustCompile(`"version":\s*"\d+"`)},
+		monitor.BodyRejects{Regexp: regexp.MustCompile(`(?i)exception`)},
+		monitor.Header{Name: "Content-Type", Value: "application/json"},
+		monitor.Header{Name: "X-Version"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		msg := "\n want %v\n got  %v"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestAssertionsEmpty(t *testing.T) {
+	got, err := loader.Expectation{}.Assertions()
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	if got != nil {
+		msg := "want no assertions, got %v"
+		t.Fatalf(msg, got)
+	}
+}
+
+func TestAssertionsError(t *testing.T) {
+	tests := map[string]loader.Expectation{
+		"status":       {Status: []string{"ok"}},
+		"class":        {Status: []string{"9xx"}},
+		"range":        {Status: []string{"299-200"}},
+		"out of range": {Status: []string{"600"}},
+		"matches":      {Body: loader.BodyExpectation{Matches: []string{"("}}},
+		"not matches":  {Body: loader.BodyExpectation{NotMatches: []string{"["}}},
+	}
+	for name, expectation := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := expectation.Assertions()
+			if err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+			if got != nil {
+				msg := "want no assertions, got %v"
+				t.Fatalf(msg, got)
+			}
+		})
+	}
+}
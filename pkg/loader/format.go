@@ -0,0 +1,85 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type Format interface {
+	Decode(reader io.Reader, v interface{}) error
+}
+
+type JSON struct{}
+
+func (JSON) Decode(reader io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(reader)
+	return decoder.Decode(v)
+}
+
+type YAML struct{}
+
+func (YAML) Decode(reader io.Reader, v interface{}) error {
+	var document interface{}
+	decoder := yaml.NewDecoder(reader)
+	if err := decoder.Decode(&document); err != nil {
+		return err
+	}
+	return convert(document, v)
+}
+
+// TOML has no top level arrays, definitions are declared as an array of
+// tables named definitions. Other documents are decoded as a whole.
+type TOML struct{}
+
+func (TOML) Decode(reader io.Reader, v interface{}) error {
+	document := map[string]interface{}{}
+	decoder := toml.NewDecoder(reader)
+	if _, err := decoder.Decode(&document); err != nil {
+		return err
+	}
+	if reflect.Indirect(reflect.ValueOf(v)).Kind() == reflect.Slice {
+		return convert(document["definitions"], v)
+	}
+	return convert(document, v)
+}
+
+// convert maps a decoded document onto v through JSON, so every format
+// shares the same field names and validation.
+func convert(document interface{}, v interface{}) error {
+	content, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return JSON{}.Decode(strings.NewReader(string(content)), v)
+}
+
+var formats = map[string]Format{
+	"json": JSON{},
+	"yaml": YAML{},
+	"yml":  YAML{},
+	"toml": TOML{},
+}
+
+func Named(name string) (Format, error) {
+	format, ok := formats[strings.ToLower(name)]
+	if !ok {
+		err := fmt.Errorf("unknown format %q", name)
+		return nil, err
+	}
+	return format, nil
+}
+
+func Detect(path string) Format {
+	extension := strings.TrimPrefix(filepath.Ext(path), ".")
+	if format, err := Named(extension); err == nil {
+		return format
+	}
+	return JSON{}
+}
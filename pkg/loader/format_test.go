@@ -0,0 +1,197 @@
+package loader_test
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/loader"
+	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/ticker"
+)
+
+func TestFormats(t *testing.T) {
+	want := []ticker.Entry{
+		{
+			Job: monitor.Job{
+				Location:   location(t, "https://domain-1.com/health"),
+				Method:     "GET",
+				Header:     http.Header{"Accept": []string{"application/json"}},
+				Assertions: []monitor.Assertion{monitor.Status{{Min: 200, Max: 299}}},
+			},
+			Schedule: ticker.Interval(5 * time.Minute),
+		},
+		{
+			Job: monitor.Job{
+				Location: location(t, "https://domain-2.com"),
+				Method:   "HEAD",
+			},
+			Schedule: ticker.Interval(time.Hour),
+		},
+	}
+	tests := map[string]loader.Format{
+		"testdata/definitions.json": nil,
+		"testdata/definitions.yaml": nil,
+		"testdata/definitions.toml": loader.TOML{},
+	}
+	for path, format := range tests {
+		t.Run(path, func(t *testing.T) {
+			logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+			sut, err := loader.File(path, format, logger)
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			got, err := sut.Load()
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			if !reflect.DeepEqual(want, got) {
+				msg := "\n want %v\n got  %v"
+				t.Fatalf(msg, want, got)
+			}
+		})
+	}
+}
+
+func TestFormatsStatusCodes(t *testing.T) {
+	want := []monitor.Assertion{monitor.Status{{Min: 200, Max: 200}, {Min: 204, Max: 204}, {Min: 300, Max: 399}}}
+	tests := map[string]struct {
+		format   loader.Format
+		document string
+	}{
+		"json": {
+			format:   loader.JSON{},
+			document: `[{"location": "https://domain.com", "frequency": "1m", "expect": {"status": [200, 204, "3xx"]}}]`,
+		},
+		"yaml": {
+			format:   loader.YAML{},
+			document: "- location: https://domain.com\n  frequency: 1m\n  expect:\n    status: [200, 204, 3xx]\n",
+		},
+		"toml": {
+			format:   loader.TOML{},
+			document: "[[definitions]]\nlocation = \"https://domain.com\"\nfrequency = \"1m\"\nexpect = { status = [200, 204, \"3xx\"] }\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+			sut := loader.New(io.NopCloser(strings.NewReader(test.document)), test.format, logger)
+			got, err := sut.Load()
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			if len(got) != 1 || !reflect.DeepEqual(want, got[0].Job.Assertions) {
+				msg := "\n want %v\n got  %v"
+				t.Fatalf(msg, want, got)
+			}
+		})
+	}
+}
+
+func TestFormatsMismatch(t *testing.T) {
+	tests := map[string]loader.Format{
+		"testdata/definitions.json": loader.TOML{},
+		"testdata/definitions.yaml": loader.TOML{},
+		"testdata/definitions.toml": loader.JSON{},
+	}
+	for path, format := range tests {
+		t.Run(path, func(t *testing.T) {
+			logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+			sut, err := loader.File(path, format, logger)
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			if _, err := sut.Load(); err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+		})
+	}
+}
+
+func TestNamed(t *testing.T) {
+	tests := map[string]loader.Format{
+		"json": loader.JSON{},
+		"YAML": loader.YAML{},
+		"yml":  loader.YAML{},
+		"toml": loader.TOML{},
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := loader.Named(name)
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			if got != want {
+				msg := "want %T, got %T"
+				t.Fatalf(msg, want, got)
+			}
+		})
+	}
+}
+
+func TestNamedError(t *testing.T) {
+	got, err := loader.Named("xml")
+	if err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+	if got != nil {
+		msg := "want nothing, got %T"
+		t.Fatalf(msg, got)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := map[string]loader.Format{
+		"definitions.json":  loader.JSON{},
+		"definitions.yaml":  loader.YAML{},
+		"definitions.yml":   loader.YAML{},
+		"definitions.toml":  loader.TOML{},
+		"definitions.conf":  loader.JSON{},
+		"definitions":       loader.JSON{},
+		"/etc/baal/sites.Y": loader.JSON{},
+	}
+	for path, want := range tests {
+		t.Run(path, func(t *testing.T) {
+			if got := loader.Detect(path); got != want {
+				msg := "want %T, got %T"
+				t.Fatalf(msg, want, got)
+			}
+		})
+	}
+}
+
+func TestFormatsUnknownFields(t *testing.T) {
+	document := `
+- location: https://domain-1.com
+  methd: GET
+  frequency: 1m
+`
+	reader := io.NopCloser(strings.NewReader(document))
+	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+	sut := loader.New(reader, loader.YAML{}, logger)
+	_, err := sut.Definitions()
+
+	var problems loader.Problems
+	if !errors.As(err, &problems) {
+		msg := "want problems, got %v"
+		t.Fatalf(msg, err)
+	}
+	want := loader.Problems{
+		{Index: 0, Field: "methd", Message: "unknown field"},
+	}
+	if !reflect.DeepEqual(want, problems) {
+		msg := "\n want %v\n got  %v"
+		t.Fatalf(msg, want, problems)
+	}
+}
@@ -2,73 +2,300 @@ package loader
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/ticker"
+	"github.com/ksahli/baal/pkg/tracker"
 )
 
 type Definition struct {
 	Location  string `json:"location"`
 	Frequency string `json:"frequency"`
-	Method    string `josn:"method"`
+	Schedule  string `json:"schedule"`
+	Timezone  string `json:"timezone"`
+	Method    string `json:"method"`
+
+	Timeout string            `json:"timeout"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	Auth    *Auth             `json:"auth"`
+
+	Redirects string `json:"redirects"`
+
+	Expect      Expectation   `json:"expect"`
+	Certificate Certificate   `json:"certificate"`
+	Thresholds  Thresholds    `json:"thresholds"`
+	Recipients  []string      `json:"recipients"`
+	Flapping    Flapping      `json:"flapping"`
+	Renotify    string        `json:"renotify"`
+	Maintenance []Maintenance `json:"maintenance"`
+}
+
+func (d Definition) Entry() (ticker.Entry, error) {
+	schedule, err := d.schedule()
+	if err != nil {
+		return ticker.Entry{}, err
+	}
+	job, err := d.Job()
+	if err != nil {
+		return ticker.Entry{}, err
+	}
+	entry := ticker.Entry{
+		Job:      job,
+		Schedule: schedule,
+	}
+	return entry, nil
+}
+
+func (d Definition) schedule() (ticker.Schedule, error) {
+	if d.Schedule == "" {
+		frequency, err := time.ParseDuration(d.Frequency)
+		if err != nil {
+			return nil, err
+		}
+		return ticker.Interval(frequency), nil
+	}
+	location, err := time.LoadLocation(d.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return ticker.ParseCron(d.Schedule, location)
+}
+
+func (d Definition) Policy() (tracker.Policy, error) {
+	policy := tracker.Policy{
+		Failures:  d.Thresholds.Failures,
+		Successes: d.Thresholds.Successes,
+		Flapping:  tracker.Flapping{Changes: d.Flapping.Changes},
+	}
+	var err error
+	if d.Flapping.Window != "" {
+		if policy.Flapping.Window, err = time.ParseDuration(d.Flapping.Window); err != nil {
+			return tracker.Policy{}, err
+		}
+	}
+	if d.Renotify != "" {
+		if policy.Renotify, err = time.ParseDuration(d.Renotify); err != nil {
+			return tracker.Policy{}, err
+		}
+	}
+	for _, maintenance := range d.Maintenance {
+		window, err := maintenance.Window()
+		if err != nil {
+			return tracker.Policy{}, err
+		}
+		policy.Maintenance = append(policy.Maintenance, window)
+	}
+	return policy, nil
+}
+
+func (d Definition) Job() (monitor.Job, error) {
+	location, err := url.Parse(d.Location)
+	if err != nil {
+		return monitor.Job{}, err
+	}
+	var timeout time.Duration
+	if d.Timeout != "" {
+		timeout, err = time.ParseDuration(d.Timeout)
+		if err != nil {
+			return monitor.Job{}, err
+		}
+	}
+	var header http.Header
+	if len(d.Headers) > 0 {
+		header = http.Header{}
+		for name, value := range d.Headers {
+			header.Set(name, value)
+		}
+	}
+	var body []byte
+	if d.Body != "" {
+		body = []byte(d.Body)
+	}
+	credentials, err := d.Auth.Credentials()
+	if err != nil {
+		return monitor.Job{}, err
+	}
+	redirects, err := maxRedirects(d.Redirects)
+	if err != nil {
+		return monitor.Job{}, err
+	}
+	assertions, err := d.Expect.Assertions()
+	if err != nil {
+		return monitor.Job{}, err
+	}
+	if d.Certificate.Warning < 0 {
+		err := fmt.Errorf("negative certificate warning %d", d.Certificate.Warning)
+		return monitor.Job{}, err
+	}
+	job := monitor.Job{
+		Location:   location,
+		Method:     strings.ToUpper(d.Method),
+		Assertions: assertions,
+
+		Timeout:     timeout,
+		Header:      header,
+		Body:        body,
+		Credentials: credentials,
+
+		MaxRedirects:       redirects,
+		CertificateWarning: d.Certificate.Warning,
+	}
+	return job, nil
+}
+
+func maxRedirects(policy string) (int, error) {
+	policy = strings.TrimSpace(policy)
+	switch strings.ToLower(policy) {
+	case "", "follow":
+		return monitor.FollowRedirects, nil
+	case "none":
+		return monitor.NoRedirects, nil
+	}
+	max, err := strconv.Atoi(policy)
+	if err != nil || max <= 0 {
+		err := fmt.Errorf("invalid redirect policy %q", policy)
+		return 0, err
+	}
+	return max, nil
+}
+
+// Duration parses a positive duration of the configuration files read with
+// the loader formats, such as the ones of the sinks and notifiers.
+func Duration(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		err := fmt.Errorf("invalid duration %q", value)
+		return 0, err
+	}
+	return duration, nil
+}
+
+type Certificate struct {
+	Warning int `json:"warning"`
+}
+
+type Thresholds struct {
+	Failures  int `json:"failures"`
+	Successes int `json:"successes"`
+}
+
+type Flapping struct {
+	Changes int    `json:"changes"`
+	Window  string `json:"window"`
+}
+
+// Maintenance is either a period between two RFC 3339 times or a window
+// of the given duration opening on a cron schedule.
+type Maintenance struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Schedule string `json:"schedule"`
+	Duration string `json:"duration"`
+	Timezone string `json:"timezone"`
+}
+
+func (m Maintenance) Window() (tracker.Window, error) {
+	switch {
+	case m.Schedule != "" && (m.Start != "" || m.End != ""):
+		return nil, errors.New("schedule cannot be combined with start and end")
+	case m.Schedule == "":
+		return tracker.ParsePeriod(m.Start + "/" + m.End)
+	}
+	location, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		err := fmt.Errorf("unknown timezone %q", m.Timezone)
+		return nil, err
+	}
+	schedule, err := ticker.ParseCron(m.Schedule, location)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := time.ParseDuration(m.Duration)
+	if err != nil || duration <= 0 {
+		err := fmt.Errorf("invalid duration %q", m.Duration)
+		return nil, err
+	}
+	return tracker.Recurring{Schedule: schedule, Duration: duration}, nil
 }
 
 type Loader struct {
-	logger  *log.Logger
-	decoder *json.Decoder
+	logger *log.Logger
+	format Format
 
-	closer io.Closer
+	reader io.ReadCloser
 }
 
-func (l Loader) Load() (map[time.Duration][]monitor.Job, error) {
-	definitions := []Definition{}
-	defer l.closer.Close()
-	if err := l.decoder.Decode(&definitions); err != nil {
+// Definitions decodes and validates every definition. When some are invalid
+// it returns the Problems as its error, along with the definitions decoded
+// so far so they can still be checked for warnings.
+func (l Loader) Definitions() ([]Definition, error) {
+	defer l.reader.Close()
+	messages := []json.RawMessage{}
+	if err := l.format.Decode(l.reader, &messages); err != nil {
 		err := fmt.Errorf("loader error: %w", err)
 		return nil, err
 	}
-	jobs := map[time.Duration][]monitor.Job{}
-	for _, definition := range definitions {
-		duration, err := time.ParseDuration(definition.Frequency)
-		if err != nil {
-			err := fmt.Errorf("loader error: %w", err)
-			return nil, err
+	definitions, problems := make([]Definition, len(messages)), Problems{}
+	for index, message := range messages {
+		invalid, ok := decode(index, message, &definitions[index])
+		problems = append(problems, invalid...)
+		if ok {
+			problems = append(problems, definitions[index].Validate(index)...)
 		}
-		location, err := url.Parse(definition.Location)
+	}
+	if len(problems) > 0 {
+		err := fmt.Errorf("loader error: %w", problems)
+		return definitions, err
+	}
+	return definitions, nil
+}
+
+func (l Loader) Load() ([]ticker.Entry, error) {
+	definitions, err := l.Definitions()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ticker.Entry, 0, len(definitions))
+	for _, definition := range definitions {
+		entry, err := definition.Entry()
 		if err != nil {
 			err := fmt.Errorf("loader error: %w", err)
 			return nil, err
 		}
-		job := monitor.Job{
-			Location: location,
-			Method:   definition.Method,
-		}
-		jobs[duration] = append(jobs[duration], job)
+		entries = append(entries, entry)
 	}
-	return jobs, nil
+	return entries, nil
 }
 
-func New(reader io.ReadCloser, logger *log.Logger) *Loader {
-	decoder := json.NewDecoder(reader)
+func New(reader io.ReadCloser, format Format, logger *log.Logger) *Loader {
 	loader := Loader{
-		logger:  logger,
-		decoder: decoder,
-		closer:  reader,
+		logger: logger,
+		format: format,
+		reader: reader,
 	}
 	return &loader
 }
 
-func File(path string, logger *log.Logger) (*Loader, error) {
+func File(path string, format Format, logger *log.Logger) (*Loader, error) {
 	file, err := os.OpenFile(path, os.O_RDONLY, 0644)
 	if err != nil {
 		err := fmt.Errorf("loader: %w", err)
 		return nil, err
 	}
-	loader := New(file, logger)
+	if format == nil {
+		format = Detect(path)
+	}
+	loader := New(file, format, logger)
 	return loader, nil
 }
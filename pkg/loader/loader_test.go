@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"reflect"
@@ -14,28 +15,33 @@ import (
 
 	"github.com/ksahli/baal/pkg/loader"
 	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/ticker"
+	"github.com/ksahli/baal/pkg/tracker"
 )
 
 type Reader struct {
 	fail        bool
 	definitions []loader.Definition
+	buffer      *bytes.Buffer
 }
 
-func (r Reader) Read(p []byte) (int, error) {
+func (r *Reader) Read(p []byte) (int, error) {
 	if r.fail {
 		err := errors.New(`test reader error`)
 		return 0, err
 	}
-	buffer := new(bytes.Buffer)
-	encoder := json.NewEncoder(buffer)
-	if err := encoder.Encode(&r.definitions); err != nil {
-		err := fmt.Errorf(`test reader error: %w`, err)
-		return 0, err
+	if r.buffer == nil {
+		r.buffer = new(bytes.Buffer)
+		encoder := json.NewEncoder(r.buffer)
+		if err := encoder.Encode(&r.definitions); err != nil {
+			err := fmt.Errorf(`test reader error: %w`, err)
+			return 0, err
+		}
 	}
-	return buffer.Read(p)
+	return r.buffer.Read(p)
 }
 
-func (r Reader) Close() error {
+func (r *Reader) Close() error {
 	return nil
 }
 
@@ -51,74 +57,86 @@ func TestLoad(t *testing.T) {
 	reader := Reader{
 		definitions: []loader.Definition{
 			{
-				Location:  "http:/domain-1.com",
-				Method:    "GET",
+				Location:  "http://domain-1.com",
+				Method:    "get",
 				Frequency: "5m",
 			},
 			{
-				Location:  "http:/domain-2.com",
+				Location:  "http://domain-2.com",
 				Method:    "GET",
 				Frequency: "5m",
 			},
 			{
-				Location:  "http:/domain-3.com",
+				Location:  "http://domain-3.com",
 				Method:    "GET",
 				Frequency: "15m",
 			},
 			{
-				Location:  "http:/domain-4.com",
+				Location:  "http://domain-4.com",
 				Method:    "GET",
 				Frequency: "15m",
 			},
 			{
-				Location:  "http:/domain-5.com",
+				Location:  "http://domain-5.com",
 				Method:    "GET",
 				Frequency: "1h",
 			},
 			{
-				Location:  "http:/domain-6.com",
+				Location:  "http://domain-6.com",
 				Method:    "GET",
 				Frequency: "1h",
 			},
 		},
 	}
 	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
-	sut := loader.New(&reader, logger)
+	sut := loader.New(&reader, loader.JSON{}, logger)
 	got, err := sut.Load()
 	if err != nil {
 		msg := "unwanted error: %v"
 		t.Fatalf(msg, err)
 	}
-	want := map[time.Duration][]monitor.Job{
-		5 * time.Minute: []monitor.Job{
-			{
-				Location: location(t, "http:/domain-1.com"),
+	want := []ticker.Entry{
+		{
+			Job: monitor.Job{
+				Location: location(t, "http://domain-1.com"),
 				Method:   "GET",
 			},
-			{
-				Location: location(t, "http:/domain-2.com"),
+			Schedule: ticker.Interval(5 * time.Minute),
+		},
+		{
+			Job: monitor.Job{
+				Location: location(t, "http://domain-2.com"),
 				Method:   "GET",
 			},
+			Schedule: ticker.Interval(5 * time.Minute),
 		},
-		15 * time.Minute: []monitor.Job{
-			{
-				Location: location(t, "http:/domain-3.com"),
+		{
+			Job: monitor.Job{
+				Location: location(t, "http://domain-3.com"),
 				Method:   "GET",
 			},
-			{
-				Location: location(t, "http:/domain-4.com"),
+			Schedule: ticker.Interval(15 * time.Minute),
+		},
+		{
+			Job: monitor.Job{
+				Location: location(t, "http://domain-4.com"),
 				Method:   "GET",
 			},
+			Schedule: ticker.Interval(15 * time.Minute),
 		},
-		time.Hour: []monitor.Job{
-			{
-				Location: location(t, "http:/domain-5.com"),
+		{
+			Job: monitor.Job{
+				Location: location(t, "http://domain-5.com"),
 				Method:   "GET",
 			},
-			{
-				Location: location(t, "http:/domain-6.com"),
+			Schedule: ticker.Interval(time.Hour),
+		},
+		{
+			Job: monitor.Job{
+				Location: location(t, "http://domain-6.com"),
 				Method:   "GET",
 			},
+			Schedule: ticker.Interval(time.Hour),
 		},
 	}
 	if !reflect.DeepEqual(want, got) {
@@ -130,7 +148,7 @@ func TestLoad(t *testing.T) {
 func TestLoadDecoderError(t *testing.T) {
 	reader := Reader{fail: true}
 	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
-	sut := loader.New(&reader, logger)
+	sut := loader.New(&reader, loader.JSON{}, logger)
 	got, err := sut.Load()
 	if err == nil {
 		t.Fatal("want an error, got nothing")
@@ -152,7 +170,7 @@ func TestLoadParseLocationError(t *testing.T) {
 		},
 	}
 	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
-	sut := loader.New(&reader, logger)
+	sut := loader.New(&reader, loader.JSON{}, logger)
 	got, err := sut.Load()
 	if err == nil {
 		t.Fatal("want an error, got nothing")
@@ -167,14 +185,14 @@ func TestLoadParseDurationError(t *testing.T) {
 	reader := Reader{
 		definitions: []loader.Definition{
 			{
-				Location:  "http:/domain-1.com",
+				Location:  "http://domain-1.com",
 				Method:    "GET",
 				Frequency: "invalid duration",
 			},
 		},
 	}
 	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
-	sut := loader.New(&reader, logger)
+	sut := loader.New(&reader, loader.JSON{}, logger)
 	got, err := sut.Load()
 	if err == nil {
 		t.Fatal("want an error, got nothing")
@@ -193,7 +211,7 @@ func TestFile(t *testing.T) {
 		t.Fatalf(msg, err)
 	}
 	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
-	loader, err := loader.File(path, logger)
+	loader, err := loader.File(path, nil, logger)
 	if err != nil {
 		msg := "unwanted error %v"
 		t.Fatalf(msg, err)
@@ -205,7 +223,7 @@ func TestFile(t *testing.T) {
 
 func TestFileError(t *testing.T) {
 	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
-	loader, err := loader.File("invalid path", logger)
+	loader, err := loader.File("invalid path", nil, logger)
 	if err == nil {
 		t.Fatal("want an error, got nothing")
 	}
@@ -214,3 +232,287 @@ func TestFileError(t *testing.T) {
 		t.Fatalf(msg, loader)
 	}
 }
+
+func TestLoadCertificate(t *testing.T) {
+	reader := Reader{
+		definitions: []loader.Definition{
+			{
+				Location:    "https://domain-1.com",
+				Method:      "GET",
+				Frequency:   "1h",
+				Certificate: loader.Certificate{Warning: 14},
+			},
+		},
+	}
+	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+	sut := loader.New(&reader, loader.JSON{}, logger)
+	got, err := sut.Load()
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	want := []ticker.Entry{
+		{
+			Job: monitor.Job{
+				Location:           location(t, "https://domain-1.com"),
+				Method:             "GET",
+				CertificateWarning: 14,
+			},
+			Schedule: ticker.Interval(time.Hour),
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		msg := "\n want %v\n got  %v"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestLoadCertificateError(t *testing.T) {
+	reader := Reader{
+		definitions: []loader.Definition{
+			{
+				Location:    "https://domain-1.com",
+				Method:      "GET",
+				Frequency:   "1h",
+				Certificate: loader.Certificate{Warning: -1},
+			},
+		},
+	}
+	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+	sut := loader.New(&reader, loader.JSON{}, logger)
+	got, err := sut.Load()
+	if err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+	if len(got) != 0 {
+		msg := "want no definitions, got %d"
+		t.Fatalf(msg, len(got))
+	}
+}
+
+func TestLoadRequest(t *testing.T) {
+	t.Setenv("BAAL_TEST_TOKEN", "token")
+	reader := Reader{
+		definitions: []loader.Definition{
+			{
+				Location:  "https://domain-1.com/health",
+				Method:    "POST",
+				Frequency: "1m",
+				Timeout:   "5s",
+				Headers:   map[string]string{"content-type": "application/json"},
+				Body:      `{"ping": true}`,
+				Auth: &loader.Auth{
+					Type:  "bearer",
+					Token: loader.Secret{Env: "BAAL_TEST_TOKEN"},
+				},
+			},
+		},
+	}
+	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+	sut := loader.New(&reader, loader.JSON{}, logger)
+	got, err := sut.Load()
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	want := []ticker.Entry{
+		{
+			Job: monitor.Job{
+				Location:    location(t, "https://domain-1.com/health"),
+				Method:      "POST",
+				Timeout:     5 * time.Second,
+				Header:      http.Header{"Content-Type": []string{"application/json"}},
+				Body:        []byte(`{"ping": true}`),
+				Credentials: monitor.Bearer("token"),
+			},
+			Schedule: ticker.Interval(time.Minute),
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		msg := "\n want %v\n got  %v"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestLoadRequestError(t *testing.T) {
+	tests := map[string]loader.Definition{
+		"timeout": {
+			Location:  "https://domain-1.com",
+			Method:    "GET",
+			Frequency: "1m",
+			Timeout:   "soon",
+		},
+		"auth": {
+			Location:  "https://domain-1.com",
+			Method:    "GET",
+			Frequency: "1m",
+			Auth:      &loader.Auth{Type: "bearer"},
+		},
+	}
+	for name, definition := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := Reader{definitions: []loader.Definition{definition}}
+			logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+			sut := loader.New(&reader, loader.JSON{}, logger)
+			got, err := sut.Load()
+			if err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+			if len(got) != 0 {
+				msg := "want no definitions, got %d"
+				t.Fatalf(msg, len(got))
+			}
+		})
+	}
+}
+
+func TestLoadRedirects(t *testing.T) {
+	tests := map[string]struct {
+		policy string
+		want   int
+	}{
+		"default": {policy: "", want: monitor.FollowRedirects},
+		"follow":  {policy: "follow", want: monitor.FollowRedirects},
+		"none":    {policy: "none", want: monitor.NoRedirects},
+		"limited": {policy: "3", want: 3},
+		"padded":  {policy: " 3 ", want: 3},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := Reader{
+				definitions: []loader.Definition{
+					{
+						Location:  "https://domain-1.com",
+						Method:    "GET",
+						Frequency: "1m",
+						Redirects: test.policy,
+					},
+				},
+			}
+			logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+			sut := loader.New(&reader, loader.JSON{}, logger)
+			got, err := sut.Load()
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			if max := got[0].Job.MaxRedirects; max != test.want {
+				msg := "want %d, got %d"
+				t.Fatalf(msg, test.want, max)
+			}
+		})
+	}
+}
+
+func TestLoadRedirectsError(t *testing.T) {
+	for _, policy := range []string{"always", "0", "-2"} {
+		t.Run(policy, func(t *testing.T) {
+			reader := Reader{
+				definitions: []loader.Definition{
+					{
+						Location:  "https://domain-1.com",
+						Method:    "GET",
+						Frequency: "1m",
+						Redirects: policy,
+					},
+				},
+			}
+			logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+			sut := loader.New(&reader, loader.JSON{}, logger)
+			if _, err := sut.Load(); err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+		})
+	}
+}
+
+func TestLoadSchedule(t *testing.T) {
+	reader := Reader{
+		definitions: []loader.Definition{
+			{
+				Location: "https://domain-1.com",
+				Method:   "GET",
+				Schedule: "*/5 9-18 * * MON-FRI",
+				Timezone: "Europe/Paris",
+			},
+		},
+	}
+	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+	sut := loader.New(&reader, loader.JSON{}, logger)
+	got, err := sut.Load()
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	if len(got) != 1 {
+		msg := "want 1 entry, got %d"
+		t.Fatalf(msg, len(got))
+	}
+	cron, ok := got[0].Schedule.(ticker.Cron)
+	if !ok {
+		msg := "want a cron schedule, got %T"
+		t.Fatalf(msg, got[0].Schedule)
+	}
+	if cron.String() != "*/5 9-18 * * MON-FRI" || cron.Location().String() != "Europe/Paris" {
+		msg := "want the definition schedule and timezone, got %q in %s"
+		t.Fatalf(msg, cron, cron.Location())
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	definition := loader.Definition{
+		Location:   "https://domain-1.com",
+		Frequency:  "1m",
+		Thresholds: loader.Thresholds{Failures: 3, Successes: 2},
+		Flapping:   loader.Flapping{Changes: 4, Window: "1h"},
+		Renotify:   "30m",
+		Maintenance: []loader.Maintenance{
+			{Start: "2022-01-01T02:00:00Z", End: "2022-01-01T04:00:00Z"},
+			{Schedule: "0 2 * * SUN", Duration: "2h", Timezone: "Europe/Paris"},
+		},
+	}
+	got, err := definition.Policy()
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	want := tracker.Policy{
+		Failures:  3,
+		Successes: 2,
+		Flapping:  tracker.Flapping{Changes: 4, Window: time.Hour},
+		Renotify:  30 * time.Minute,
+	}
+	maintenance := got.Maintenance
+	got.Maintenance = nil
+	if !reflect.DeepEqual(want, got) {
+		msg := "\nwant %+v\n  got %+v"
+		t.Fatalf(msg, want, got)
+	}
+
+	period := tracker.Period{
+		Start: time.Date(2022, 1, 1, 2, 0, 0, 0, time.UTC),
+		End:   time.Date(2022, 1, 1, 4, 0, 0, 0, time.UTC),
+	}
+	if len(maintenance) != 2 || maintenance[0] != period {
+		msg := "want the maintenance period %+v first, got %+v"
+		t.Fatalf(msg, period, maintenance)
+	}
+	if !maintenance[1].Active(time.Date(2022, 1, 2, 1, 30, 0, 0, time.UTC)) {
+		msg := "want the recurring maintenance active on sunday 2:30 in Paris, got %+v"
+		t.Fatalf(msg, maintenance[1])
+	}
+}
+
+func TestDuration(t *testing.T) {
+	if got, err := loader.Duration("90s"); err != nil || got != 90*time.Second {
+		msg := "want 1m30s, got %v (%v)"
+		t.Fatalf(msg, got, err)
+	}
+	for _, value := range []string{"", "soon", "0s", "-1m"} {
+		if _, err := loader.Duration(value); err == nil {
+			msg := "want an error for %q, got nothing"
+			t.Fatalf(msg, value)
+		}
+	}
+}
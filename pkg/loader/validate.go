@@ -0,0 +1,318 @@
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ksahli/baal/pkg/ticker"
+)
+
+const MinimumFrequency = time.Second
+
+var methods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+type Problem struct {
+	Index   int
+	Field   string
+	Message string
+}
+
+func (p Problem) Error() string {
+	return fmt.Sprintf("definition %d: %s: %s", p.Index, p.Field, p.Message)
+}
+
+type Problems []Problem
+
+func (p Problems) Error() string {
+	messages := make([]string, 0, len(p))
+	for _, problem := range p {
+		messages = append(messages, problem.Error())
+	}
+	return fmt.Sprintf("%d invalid definition fields: %s", len(p), strings.Join(messages, "; "))
+}
+
+func (d Definition) Validate(index int) Problems {
+	problems := Problems{}
+	problem := func(field string, format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		problems = append(problems, Problem{Index: index, Field: field, Message: message})
+	}
+
+	location, err := url.Parse(d.Location)
+	switch {
+	case d.Location == "":
+		problem("location", "is required")
+	case err != nil:
+		problem("location", "%v", errors.Unwrap(err))
+	case location.Scheme != "http" && location.Scheme != "https":
+		problem("location", "scheme %q is not http or https", location.Scheme)
+	case location.Host == "":
+		problem("location", "has no host")
+	}
+
+	if d.Method != "" && !methods[strings.ToUpper(d.Method)] {
+		problem("method", "unsupported method %q", d.Method)
+	}
+
+	frequency, err := time.ParseDuration(d.Frequency)
+	switch {
+	case d.Frequency == "" && d.Schedule == "":
+		problem("frequency", "is required without a schedule")
+	case d.Frequency != "" && d.Schedule != "":
+		problem("frequency", "cannot be combined with a schedule")
+	case d.Frequency == "":
+	case err != nil:
+		problem("frequency", "invalid duration %q", d.Frequency)
+	case frequency < MinimumFrequency:
+		problem("frequency", "%s is below the minimum of %s", frequency, MinimumFrequency)
+	}
+
+	timezone, err := time.LoadLocation(d.Timezone)
+	switch {
+	case err != nil:
+		problem("timezone", "unknown timezone %q", d.Timezone)
+	case d.Timezone != "" && d.Schedule == "":
+		problem("timezone", "only applies to a schedule")
+	case d.Schedule != "":
+		if _, err := ticker.ParseCron(d.Schedule, timezone); err != nil {
+			problem("schedule", "%v", errors.Unwrap(err))
+		}
+	}
+
+	if d.Timeout != "" {
+		timeout, err := time.ParseDuration(d.Timeout)
+		switch {
+		case err != nil:
+			problem("timeout", "invalid duration %q", d.Timeout)
+		case timeout <= 0:
+			problem("timeout", "must be positive")
+		}
+	}
+
+	for name := range d.Headers {
+		if strings.TrimSpace(name) == "" {
+			problem("headers", "empty header name")
+		}
+	}
+
+	if _, err := maxRedirects(d.Redirects); err != nil {
+		problem("redirects", "%v", err)
+	}
+
+	if d.Auth != nil {
+		switch strings.ToLower(d.Auth.Type) {
+		case "basic":
+			if err := d.Auth.Password.check(); err != nil {
+				problem("auth.password", "%v", err)
+			}
+		case "bearer":
+			if err := d.Auth.Token.check(); err != nil {
+				problem("auth.token", "%v", err)
+			}
+		default:
+			problem("auth.type", "unknown auth type %q", d.Auth.Type)
+		}
+	}
+
+	for i, spec := range d.Expect.Status {
+		if _, err := statusRange(spec); err != nil {
+			problem(fmt.Sprintf("expect.status[%d]", i), "%v", err)
+		}
+	}
+	for i, expression := range d.Expect.Body.Matches {
+		if _, err := regexp.Compile(expression); err != nil {
+			problem(fmt.Sprintf("expect.body.matches[%d]", i), "%v", err)
+		}
+	}
+	for i, expression := range d.Expect.Body.NotMatches {
+		if _, err := regexp.Compile(expression); err != nil {
+			problem(fmt.Sprintf("expect.body.not_matches[%d]", i), "%v", err)
+		}
+	}
+	for name := range d.Expect.Headers {
+		if strings.TrimSpace(name) == "" {
+			problem("expect.headers", "empty header name")
+		}
+	}
+
+	if d.Certificate.Warning < 0 {
+		problem("certificate.warning", "must not be negative")
+	}
+
+	if d.Thresholds.Failures < 0 {
+		problem("thresholds.failures", "must not be negative")
+	}
+	if d.Thresholds.Successes < 0 {
+		problem("thresholds.successes", "must not be negative")
+	}
+
+	switch flapping, err := time.ParseDuration(d.Flapping.Window); {
+	case d.Flapping.Changes < 0:
+		problem("flapping.changes", "must not be negative")
+	case d.Flapping.Changes == 0 && d.Flapping.Window != "":
+		problem("flapping.window", "only applies with changes")
+	case d.Flapping.Changes == 0:
+	case err != nil || flapping <= 0:
+		problem("flapping.window", "invalid duration %q", d.Flapping.Window)
+	}
+
+	if d.Renotify != "" {
+		if renotify, err := time.ParseDuration(d.Renotify); err != nil || renotify <= 0 {
+			problem("renotify", "invalid duration %q", d.Renotify)
+		}
+	}
+
+	for i, maintenance := range d.Maintenance {
+		if _, err := maintenance.Window(); err != nil {
+			problem(fmt.Sprintf("maintenance[%d]", i), "%v", err)
+		}
+	}
+
+	for i, recipient := range d.Recipients {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			problem(fmt.Sprintf("recipients[%d]", i), "invalid address %q", recipient)
+		}
+	}
+
+	return problems
+}
+
+func decode(index int, message json.RawMessage, definition *Definition) (Problems, bool) {
+	problems := Problems{}
+	for _, field := range unknown(message, reflect.TypeOf(definition).Elem(), "") {
+		problem := Problem{Index: index, Field: field, Message: "unknown field"}
+		problems = append(problems, problem)
+	}
+	if err := json.Unmarshal(message, definition); err != nil {
+		problem := Problem{Index: index, Field: "definition", Message: err.Error()}
+		var typed *json.UnmarshalTypeError
+		if errors.As(err, &typed) {
+			problem.Field = typed.Field
+			problem.Message = fmt.Sprintf("cannot be a %s", typed.Value)
+		}
+		problems = append(problems, problem)
+		return problems, false
+	}
+	return problems, true
+}
+
+// unknown walks a raw document alongside the type it decodes into and
+// returns the path of every key the type does not declare.
+func unknown(message json.RawMessage, typ reflect.Type, path string) []string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	fields := []string{}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		object := map[string]json.RawMessage{}
+		if err := json.Unmarshal(message, &object); err != nil {
+			return nil
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field, ok := lookup(typ, key)
+			if !ok {
+				fields = append(fields, join(path, key))
+				continue
+			}
+			fields = append(fields, unknown(object[key], field.Type, join(path, key))...)
+		}
+	case reflect.Slice:
+		elements := []json.RawMessage{}
+		if err := json.Unmarshal(message, &elements); err != nil {
+			return nil
+		}
+		for i, element := range elements {
+			fields = append(fields, unknown(element, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case reflect.Map:
+		object := map[string]json.RawMessage{}
+		if err := json.Unmarshal(message, &object); err != nil {
+			return nil
+		}
+		for key, value := range object {
+			fields = append(fields, unknown(value, typ.Elem(), join(path, key))...)
+		}
+	}
+
+	return fields
+}
+
+func lookup(typ reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		if name != "-" && strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func Warnings(definitions []Definition) Problems {
+	problems, seen := Problems{}, map[string]int{}
+	for index, definition := range definitions {
+		problem := func(field string, format string, args ...interface{}) {
+			message := fmt.Sprintf(format, args...)
+			problems = append(problems, Problem{Index: index, Field: field, Message: message})
+		}
+
+		method := strings.ToUpper(definition.Method)
+		if method == "" {
+			method = http.MethodGet
+			problem("method", "is not set and defaults to GET")
+		}
+
+		location, err := url.Parse(definition.Location)
+		if err == nil && location.Scheme == "http" {
+			problem("location", "uses plain http")
+		}
+
+		key := method + " " + definition.Location
+		if first, ok := seen[key]; ok {
+			problem("location", "duplicates definition %d", first)
+		} else {
+			seen[key] = index
+		}
+
+		frequency, ferr := time.ParseDuration(definition.Frequency)
+		timeout, terr := time.ParseDuration(definition.Timeout)
+		if ferr == nil && terr == nil && timeout >= frequency {
+			problem("timeout", "%s is not shorter than the %s frequency", timeout, frequency)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,194 @@
+package loader_test
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ksahli/baal/pkg/loader"
+)
+
+func TestDefinitions(t *testing.T) {
+	document := `[
+		{
+			"location":  "https://domain-1.com",
+			"method":    "POST",
+			"frequency": "30s",
+			"timeout":   "5s"
+		}
+	]`
+	reader := io.NopCloser(strings.NewReader(document))
+	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+	sut := loader.New(reader, loader.JSON{}, logger)
+	got, err := sut.Definitions()
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	want := []loader.Definition{
+		{
+			Location:  "https://domain-1.com",
+			Method:    "POST",
+			Frequency: "30s",
+			Timeout:   "5s",
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		msg := "\n want %v\n got  %v"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestDefinitionsProblems(t *testing.T) {
+	document := `[
+		{
+			"location":  "https://domain-1.com",
+			"method":    "get",
+			"frequency": "1m"
+		},
+		{
+			"location":  "ftp://domain-2.com",
+			"method":    "FETCH",
+			"frequency": "100ms",
+			"timout":    "5s",
+			"expect":    {"status": [200, "7xx", true], "body": {"match": ["ok"]}}
+		},
+		{
+			"location":  "http:/domain-3.com",
+			"frequency": "often",
+			"timeout":   "-1s",
+			"redirects": "always",
+			"auth":      {"type": "bearer", "token": {}},
+			"certificate": {"warning": -1},
+			"thresholds":  {"failures": -2},
+			"recipients":  ["ops@domain-3.com", "ops"]
+		},
+		{
+			"location":  "https://domain-4.com",
+			"frequency": 60
+		},
+		{
+			"expect": {"body": {"matches": ["("], "not_matches": ["["]}},
+			"auth":   {"type": "digest"}
+		},
+		{
+			"location":  "https://domain-5.com",
+			"frequency": "1m",
+			"schedule":  "* * * * *"
+		},
+		{
+			"location":  "https://domain-6.com",
+			"schedule":  "*/5 9-18 * * MON-SUN",
+			"timezone":  "Mars/Olympus"
+		},
+		{
+			"location":  "https://domain-7.com",
+			"schedule":  "*/5 25 * * *",
+			"timezone":  "Europe/Paris"
+		},
+		{
+			"location":  "https://domain-8.com",
+			"frequency": "1m",
+			"timezone":  "UTC"
+		},
+		{
+			"location":    "https://domain-9.com",
+			"frequency":   "1m",
+			"flapping":    {"changes": 3},
+			"renotify":    "never",
+			"maintenance": [
+				{"start": "2022-01-01T04:00:00Z", "end": "2022-01-01T02:00:00Z"},
+				{"schedule": "0 2 * * SUN", "duration": "2h", "start": "2022-01-01T04:00:00Z"},
+				{"schedule": "0 2 * * SUN"}
+			]
+		}
+	]`
+	reader := io.NopCloser(strings.NewReader(document))
+	logger := log.New(os.Stderr, " [loader] ", log.Ldate)
+	sut := loader.New(reader, loader.JSON{}, logger)
+	got, err := sut.Definitions()
+	if err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+	if len(got) != 10 || got[0].Location != "https://domain-1.com" {
+		msg := "want the 10 decoded definitions, got %v"
+		t.Fatalf(msg, got)
+	}
+
+	var problems loader.Problems
+	if !errors.As(err, &problems) {
+		msg := "want problems, got %v"
+		t.Fatalf(msg, err)
+	}
+	want := loader.Problems{
+		{Index: 1, Field: "expect.body.match", Message: "unknown field"},
+		{Index: 1, Field: "timout", Message: "unknown field"},
+		{Index: 1, Field: "location", Message: `scheme "ftp" is not http or https`},
+		{Index: 1, Field: "method", Message: `unsupported method "FETCH"`},
+		{Index: 1, Field: "frequency", Message: "100ms is below the minimum of 1s"},
+		{Index: 1, Field: "expect.status[1]", Message: `invalid status "7xx"`},
+		{Index: 1, Field: "expect.status[2]", Message: `invalid status "true"`},
+		{Index: 2, Field: "location", Message: "has no host"},
+		{Index: 2, Field: "frequency", Message: `invalid duration "often"`},
+		{Index: 2, Field: "timeout", Message: "must be positive"},
+		{Index: 2, Field: "redirects", Message: `invalid redirect policy "always"`},
+		{Index: 2, Field: "auth.token", Message: "secret has no env or file"},
+		{Index: 2, Field: "certificate.warning", Message: "must not be negative"},
+		{Index: 2, Field: "thresholds.failures", Message: "must not be negative"},
+		{Index: 2, Field: "recipients[1]", Message: `invalid address "ops"`},
+		{Index: 3, Field: "frequency", Message: "cannot be a number"},
+		{Index: 4, Field: "location", Message: "is required"},
+		{Index: 4, Field: "frequency", Message: "is required without a schedule"},
+		{Index: 4, Field: "auth.type", Message: `unknown auth type "digest"`},
+		{Index: 4, Field: "expect.body.matches[0]", Message: "error parsing regexp: missing closing ): `(`"},
+		{Index: 4, Field: "expect.body.not_matches[0]", Message: "error parsing regexp: missing closing ]: `[`"},
+		{Index: 5, Field: "frequency", Message: "cannot be combined with a schedule"},
+		{Index: 6, Field: "timezone", Message: `unknown timezone "Mars/Olympus"`},
+		{Index: 7, Field: "schedule", Message: `invalid hour "25"`},
+		{Index: 8, Field: "timezone", Message: "only applies to a schedule"},
+		{Index: 9, Field: "flapping.window", Message: `invalid duration ""`},
+		{Index: 9, Field: "renotify", Message: `invalid duration "never"`},
+		{Index: 9, Field: "maintenance[0]", Message: `period "2022-01-01T04:00:00Z/2022-01-01T02:00:00Z": end is not after start`},
+		{Index: 9, Field: "maintenance[1]", Message: "schedule cannot be combined with start and end"},
+		{Index: 9, Field: "maintenance[2]", Message: `invalid duration ""`},
+	}
+	if !reflect.DeepEqual(want, problems) {
+		msg := "\n want %v\n got  %v"
+		t.Fatalf(msg, want, problems)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	definitions := []loader.Definition{
+		{
+			Location:  "https://domain-1.com",
+			Method:    "get",
+			Frequency: "1m",
+		},
+		{
+			Location:  "http://domain-2.com",
+			Frequency: "1m",
+			Timeout:   "2m",
+		},
+		{
+			Location:  "https://domain-1.com",
+			Frequency: "5m",
+		},
+	}
+	got := loader.Warnings(definitions)
+	want := loader.Problems{
+		{Index: 1, Field: "method", Message: "is not set and defaults to GET"},
+		{Index: 1, Field: "location", Message: "uses plain http"},
+		{Index: 1, Field: "timeout", Message: "2m0s is not shorter than the 1m0s frequency"},
+		{Index: 2, Field: "method", Message: "is not set and defaults to GET"},
+		{Index: 2, Field: "location", Message: "duplicates definition 0"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		msg := "\n want %v\n got  %v"
+		t.Fatalf(msg, want, got)
+	}
+}
@@ -0,0 +1,97 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type Assertion interface {
+	Assert(response *http.Response, body []byte) error
+}
+
+type StatusRange struct {
+	Min, Max int
+}
+
+func (r StatusRange) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprintf("%d", r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+type Status []StatusRange
+
+func (s Status) Assert(response *http.Response, body []byte) error {
+	ranges := make([]string, 0, len(s))
+	for _, r := range s {
+		if response.StatusCode >= r.Min && response.StatusCode <= r.Max {
+			return nil
+		}
+		ranges = append(ranges, r.String())
+	}
+	return fmt.Errorf("status %d not in %s", response.StatusCode, strings.Join(ranges, ", "))
+}
+
+type BodyContains string
+
+func (c BodyContains) Assert(response *http.Response, body []byte) error {
+	if !bytes.Contains(body, []byte(c)) {
+		return fmt.Errorf("body does not contain %q", string(c))
+	}
+	return nil
+}
+
+type BodyExcludes string
+
+func (e BodyExcludes) Assert(response *http.Response, body []byte) error {
+	if bytes.Contains(body, []byte(e)) {
+		return fmt.Errorf("body contains %q", string(e))
+	}
+	return nil
+}
+
+type BodyMatches struct {
+	*regexp.Regexp
+}
+
+func (m BodyMatches) Assert(response *http.Response, body []byte) error {
+	if !m.Match(body) {
+		return fmt.Errorf("body does not match %q", m.String())
+	}
+	return nil
+}
+
+type BodyRejects struct {
+	*regexp.Regexp
+}
+
+func (r BodyRejects) Assert(response *http.Response, body []byte) error {
+	if r.Match(body) {
+		return fmt.Errorf("body matches %q", r.String())
+	}
+	return nil
+}
+
+type Header struct {
+	Name, Value string
+}
+
+func (h Header) Assert(response *http.Response, body []byte) error {
+	values, ok := response.Header[http.CanonicalHeaderKey(h.Name)]
+	if !ok {
+		return fmt.Errorf("header %s is missing", h.Name)
+	}
+	if h.Value == "" {
+		return nil
+	}
+	for _, value := range values {
+		if value == h.Value {
+			return nil
+		}
+	}
+	return fmt.Errorf("header %s is not %q", h.Name, h.Value)
+}
@@ -0,0 +1,97 @@
+package monitor_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+func TestDoAssertions(t *testing.T) {
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		_, _ = w.Write([]byte(`{"status": "maintenance"}`))
+	}
+	handler := http.HandlerFunc(handle)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	location, err := url.Parse(server.URL)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	tests := map[string]struct {
+		assertions []monitor.Assertion
+		passed     bool
+		violations []string
+	}{
+		"none": {
+			passed: true,
+		},
+		"passing": {
+			assertions: []monitor.Assertion{
+				monitor.Status{{Min: 500, Max: 599}},
+				monitor.BodyContains("maintenance"),
+				monitor.BodyExcludes("ok"),
+				monitor.BodyMatches{regexp.MustCompile(`"status":\s*"\w+"`)},
+				monitor.BodyRejects{regexp.MustCompile(`error`)},
+				monitor.Header{Name: "content-type"},
+				monitor.Header{Name: "Content-Type", Value: "application/json"},
+			},
+			passed: true,
+		},
+		"failing": {
+			assertions: []monitor.Assertion{
+				monitor.Status{{Min: 200, Max: 299}, {Min: 304, Max: 304}},
+				monitor.BodyContains("ok"),
+				monitor.BodyExcludes("maintenance"),
+				monitor.BodyMatches{regexp.MustCompile(`^ok$`)},
+				monitor.BodyRejects{regexp.MustCompile(`main\w+`)},
+				monitor.Header{Name: "X-Version"},
+				monitor.Header{Name: "Content-Type", Value: "text/html"},
+			},
+			passed: false,
+			violations: []string{
+				`status 503 not in 200-299, 304`,
+				`body does not contain "ok"`,
+				`body contains "maintenance"`,
+				`body does not match "^ok$"`,
+				`body matches "main\\w+"`,
+				`header X-Version is missing`,
+				`header Content-Type is not "text/html"`,
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := new(http.Client)
+			sut := monitor.New(client, stamper)
+
+			job := monitor.Job{
+				Location:   location,
+				Method:     "GET",
+				Assertions: test.assertions,
+			}
+
+			got := sut.Do(job)
+			if !got.Reachable {
+				t.Fatal("want a reachable result, got an unreachable one")
+			}
+			if got.Passed != test.passed {
+				msg := "want passed %t, got %t"
+				t.Fatalf(msg, test.passed, got.Passed)
+			}
+			if !reflect.DeepEqual(test.violations, got.Violations) {
+				msg := "\n want %q\n got  %q"
+				t.Fatalf(msg, test.violations, got.Violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math"
+	"time"
+)
+
+type Certificate struct {
+	Subject  string
+	Issuer   string
+	Names    []string
+	NotAfter time.Time
+	Verified bool
+	DaysLeft int
+	Expiring bool
+}
+
+func inspect(state *tls.ConnectionState, host string, warning int, now time.Time) *Certificate {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	leaf := state.PeerCertificates[0]
+	verified := len(state.VerifiedChains) > 0
+	if !verified {
+		intermediates := x509.NewCertPool()
+		for _, certificate := range state.PeerCertificates[1:] {
+			intermediates.AddCert(certificate)
+		}
+		options := x509.VerifyOptions{
+			DNSName:       host,
+			Intermediates: intermediates,
+			CurrentTime:   now,
+		}
+		_, err := leaf.Verify(options)
+		verified = err == nil
+	}
+	return certificate(leaf, verified, warning, now)
+}
+
+func rejected(err error, warning int, now time.Time) *Certificate {
+	var (
+		authority x509.UnknownAuthorityError
+		invalid   x509.CertificateInvalidError
+		hostname  x509.HostnameError
+	)
+	switch {
+	case errors.As(err, &authority) && authority.Cert != nil:
+		return certificate(authority.Cert, false, warning, now)
+	case errors.As(err, &invalid) && invalid.Cert != nil:
+		return certificate(invalid.Cert, false, warning, now)
+	case errors.As(err, &hostname) && hostname.Certificate != nil:
+		return certificate(hostname.Certificate, false, warning, now)
+	}
+	return nil
+}
+
+func certificate(leaf *x509.Certificate, verified bool, warning int, now time.Time) *Certificate {
+	names := append([]string{}, leaf.DNSNames...)
+	for _, address := range leaf.IPAddresses {
+		names = append(names, address.String())
+	}
+	// Days left count down to -1 as soon as the certificate expired, which
+	// is always reported as expiring.
+	days := int(math.Floor(leaf.NotAfter.Sub(now).Hours() / 24))
+	certificate := Certificate{
+		Subject:  leaf.Subject.String(),
+		Issuer:   leaf.Issuer.String(),
+		Names:    names,
+		NotAfter: leaf.NotAfter.UTC(),
+		Verified: verified,
+		DaysLeft: days,
+		Expiring: now.After(leaf.NotAfter) || (warning > 0 && days <= warning),
+	}
+	return &certificate
+}
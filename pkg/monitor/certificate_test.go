@@ -0,0 +1,129 @@
+package monitor_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+func TestDoCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	location, err := url.Parse(server.URL)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	insecure := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	tests := map[string]struct {
+		client    *http.Client
+		warning   int
+		reachable bool
+		verified  bool
+		expiring  bool
+	}{
+		"verified": {
+			client:    server.Client(),
+			reachable: true,
+			verified:  true,
+		},
+		"expiring": {
+			client:    server.Client(),
+			warning:   1 << 20,
+			reachable: true,
+			verified:  true,
+			expiring:  true,
+		},
+		"unknown authority": {
+			client: new(http.Client),
+		},
+		"insecure": {
+			client:    insecure,
+			reachable: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sut := monitor.New(test.client, stamper)
+			job := monitor.Job{
+				Location:           location,
+				Method:             "GET",
+				CertificateWarning: test.warning,
+			}
+
+			result := sut.Do(job)
+			if result.Reachable != test.reachable {
+				msg := "want reachable %t, got %t"
+				t.Fatalf(msg, test.reachable, result.Reachable)
+			}
+
+			got := result.Certificate
+			if got == nil {
+				t.Fatal("want a certificate, got nothing")
+			}
+			if got.Verified != test.verified {
+				msg := "want verified %t, got %t"
+				t.Fatalf(msg, test.verified, got.Verified)
+			}
+			if got.Expiring != test.expiring {
+				msg := "want expiring %t, got %t"
+				t.Fatalf(msg, test.expiring, got.Expiring)
+			}
+			if got.DaysLeft <= 0 {
+				msg := "want days left before expiry, got %d"
+				t.Fatalf(msg, got.DaysLeft)
+			}
+			if got.Subject == "" || got.Issuer == "" {
+				msg := "want a subject and an issuer, got %q and %q"
+				t.Fatalf(msg, got.Subject, got.Issuer)
+			}
+			names := map[string]bool{}
+			for _, name := range got.Names {
+				names[name] = true
+			}
+			if !names["example.com"] || !names["127.0.0.1"] {
+				msg := "want example.com and 127.0.0.1 in names, got %v"
+				t.Fatalf(msg, got.Names)
+			}
+		})
+	}
+}
+
+func TestDoCertificateExpired(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	location, err := url.Parse(server.URL)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	// The leaf expired 20 hours before the probe, no warning is set.
+	expired := func() time.Time {
+		return server.Certificate().NotAfter.Add(20 * time.Hour)
+	}
+	sut := monitor.New(server.Client(), expired)
+	result := sut.Do(monitor.Job{Location: location, Method: "GET"})
+
+	got := result.Certificate
+	if got == nil {
+		t.Fatal("want a certificate, got nothing")
+	}
+	if !got.Expiring || got.DaysLeft != -1 {
+		msg := "want an expiring certificate with -1 days left, got expiring %t with %d days left"
+		t.Fatalf(msg, got.Expiring, got.DaysLeft)
+	}
+}
@@ -0,0 +1,23 @@
+package monitor
+
+import (
+	"net/http"
+)
+
+type Credentials interface {
+	Authorize(request *http.Request)
+}
+
+type Basic struct {
+	Username, Password string
+}
+
+func (b Basic) Authorize(request *http.Request) {
+	request.SetBasicAuth(b.Username, b.Password)
+}
+
+type Bearer string
+
+func (b Bearer) Authorize(request *http.Request) {
+	request.Header.Set("Authorization", "Bearer "+string(b))
+}
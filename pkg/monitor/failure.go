@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+type Failure string
+
+const (
+	DNSFailure         Failure = "dns"
+	ConnectionRefused  Failure = "refused"
+	ConnectionReset    Failure = "reset"
+	NetworkUnreachable Failure = "unreachable"
+	Timeout            Failure = "timeout"
+	TLSFailure         Failure = "tls"
+	TooManyRedirects   Failure = "redirects"
+	Canceled           Failure = "canceled"
+	InvalidRequest     Failure = "request"
+	Unknown            Failure = "unknown"
+)
+
+func Classify(err error) Failure {
+	if err == nil {
+		return ""
+	}
+
+	var (
+		dns         *net.DNSError
+		authority   x509.UnknownAuthorityError
+		invalid     x509.CertificateInvalidError
+		hostname    x509.HostnameError
+		record      tls.RecordHeaderError
+		network     net.Error
+		unsupported *net.AddrError
+	)
+
+	switch {
+	case errors.As(err, &dns):
+		return DNSFailure
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return ConnectionReset
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		return NetworkUnreachable
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.As(err, &network) && network.Timeout():
+		return Timeout
+	case errors.As(err, &authority), errors.As(err, &invalid), errors.As(err, &hostname):
+		return TLSFailure
+	case errors.As(err, &record), strings.Contains(err.Error(), "tls: "):
+		return TLSFailure
+	case errors.Is(err, ErrTooManyRedirects), strings.Contains(err.Error(), "stopped after"):
+		return TooManyRedirects
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ConnectionReset
+	case errors.As(err, &unsupported), strings.Contains(err.Error(), "unsupported protocol scheme"):
+		return InvalidRequest
+	}
+
+	return Unknown
+}
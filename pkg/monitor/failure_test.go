@@ -0,0 +1,127 @@
+package monitor_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+func TestClassify(t *testing.T) {
+	dns := &net.DNSError{Err: "no such host", Name: "invalid.test", IsNotFound: true}
+	tests := map[string]struct {
+		err  error
+		want monitor.Failure
+	}{
+		"nothing": {
+			err:  nil,
+			want: "",
+		},
+		"dns": {
+			err:  fmt.Errorf("dial: %w", dns),
+			want: monitor.DNSFailure,
+		},
+		"unknown": {
+			err:  errors.New("something else"),
+			want: monitor.Unknown,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := monitor.Classify(test.err); got != test.want {
+				msg := "want %q, got %q"
+				t.Fatalf(msg, test.want, got)
+			}
+		})
+	}
+}
+
+func TestDoFailures(t *testing.T) {
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	secure := httptest.NewTLSServer(http.NotFoundHandler())
+	defer secure.Close()
+
+	reset := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connection, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		connection.Close()
+	}))
+	defer reset.Close()
+
+	loop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path, http.StatusFound)
+	}))
+	defer loop.Close()
+
+	tests := map[string]struct {
+		location string
+		client   *http.Client
+		want     monitor.Failure
+	}{
+		"refused": {
+			location: refused.URL,
+			client:   new(http.Client),
+			want:     monitor.ConnectionRefused,
+		},
+		"timeout": {
+			location: slow.URL,
+			client:   &http.Client{Timeout: 10 * time.Millisecond},
+			want:     monitor.Timeout,
+		},
+		"tls": {
+			location: secure.URL,
+			client:   new(http.Client),
+			want:     monitor.TLSFailure,
+		},
+		"reset": {
+			location: reset.URL,
+			client:   new(http.Client),
+			want:     monitor.ConnectionReset,
+		},
+		"redirects": {
+			location: loop.URL,
+			client:   new(http.Client),
+			want:     monitor.TooManyRedirects,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			location, err := url.Parse(test.location)
+			if err != nil {
+				msg := "unwanted error %v"
+				t.Fatalf(msg, err)
+			}
+			sut := monitor.New(test.client, stamper)
+			job := monitor.Job{
+				Location: location,
+				Method:   "GET",
+			}
+			got := sut.Do(job)
+			if got.Reachable {
+				t.Fatal("want an unreachable result, got a reachable one")
+			}
+			if got.Failure != test.want {
+				msg := "want %q, got %q (%s)"
+				t.Fatalf(msg, test.want, got.Failure, got.Error)
+			}
+			if got.Error == "" {
+				t.Fatal("want an error message, got nothing")
+			}
+		})
+	}
+}
@@ -1,22 +1,95 @@
 package monitor
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"sync"
 	"time"
 )
 
+const limit = 1 << 20
+
 type Job struct {
-	Location *url.URL
-	Method   string
+	Location   *url.URL
+	Method     string
+	Assertions []Assertion
+
+	Timeout     time.Duration
+	Header      http.Header
+	Body        []byte
+	Credentials Credentials
+
+	MaxRedirects       int
+	CertificateWarning int
+}
+
+func (j Job) request(ctx context.Context) (*http.Request, error) {
+	var body io.Reader
+	if len(j.Body) > 0 {
+		body = bytes.NewReader(j.Body)
+	}
+	request, err := http.NewRequestWithContext(ctx, j.Method, j.Location.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range j.Header {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	if host := j.Header.Get("Host"); host != "" {
+		request.Host = host
+	}
+	if j.Credentials != nil {
+		j.Credentials.Authorize(request)
+	}
+	return request, nil
 }
 
 type Result struct {
-	Location  *url.URL
-	Status    int
-	Reachable bool
-	Time      time.Time
+	Location   *url.URL
+	Method     string
+	Status     int
+	Reachable  bool
+	Time       time.Time
+	Timings    Timings
+	Failure    Failure
+	Error      string
+	Passed     bool
+	Violations []string
+	Redirects  []Hop
+
+	Certificate *Certificate
+	Maintenance bool
+}
+
+// Key identifies the job of a location and method, as definitions may
+// probe a location with more than one method.
+func Key(method string, location *url.URL) string {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return method + " " + location.String()
+}
+
+func (j Job) Key() string {
+	return Key(j.Method, j.Location)
+}
+
+// method is the one of the request, GET when the job has none.
+func (j Job) method() string {
+	if j.Method == "" {
+		return http.MethodGet
+	}
+	return j.Method
+}
+
+func (r Result) Key() string {
+	return Key(r.Method, r.Location)
 }
 
 type Monitor struct {
@@ -24,34 +97,96 @@ type Monitor struct {
 	stamper func() time.Time
 	client  *http.Client
 	results chan Result
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func (m *Monitor) Do(job Job) Result {
-	request := http.Request{
-		URL:    job.Location,
-		Method: job.Method,
+	trace := newTrace(m.stamper)
+	ctx := httptrace.WithClientTrace(m.ctx, trace.client())
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
 	}
-	response, err := m.client.Do(&request)
+	redirects := newRedirects(job.MaxRedirects)
+	client := *m.client
+	client.CheckRedirect = redirects.check
+	// The timeout of the job replaces the one of the client, longer or not.
+	if job.Timeout > 0 {
+		client.Timeout = job.Timeout
+	}
+
+	request, err := job.request(ctx)
+	if err != nil {
+		return m.failure(job, trace, redirects, err)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return m.failure(job, trace, redirects, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, limit))
 	result := Result{
-		Location: job.Location,
-		Time:     m.stamper(),
+		Location:  job.Location,
+		Method:    job.method(),
+		Status:    response.StatusCode,
+		Reachable: true,
+		Timings:   trace.done(),
+		Time:      m.stamper(),
+		Redirects: redirects.recorded(),
+	}
+	host := response.Request.URL.Hostname()
+	result.Certificate = inspect(response.TLS, host, job.CertificateWarning, result.Time)
+	if err != nil {
+		result.Failure = Classify(err)
+		result.Error = err.Error()
 	}
-	if err == nil {
-		result.Reachable = true
-		result.Status = response.StatusCode
+	for _, assertion := range job.Assertions {
+		if err := assertion.Assert(response, body); err != nil {
+			result.Violations = append(result.Violations, err.Error())
+		}
 	}
+	result.Passed = result.Error == "" && len(result.Violations) == 0
 	return result
 }
 
+func (m *Monitor) failure(job Job, trace *trace, redirects *redirects, err error) Result {
+	result := Result{
+		Location:  job.Location,
+		Method:    job.method(),
+		Timings:   trace.done(),
+		Time:      m.stamper(),
+		Failure:   Classify(err),
+		Error:     err.Error(),
+		Redirects: redirects.recorded(),
+	}
+	result.Certificate = rejected(err, job.CertificateWarning, result.Time)
+	return result
+}
+
+func (m *Monitor) Process(job Job) {
+	result := m.Do(job)
+	m.results <- result
+}
+
 func (m *Monitor) Run(wg *sync.WaitGroup, jobs <-chan Job) {
 	defer wg.Done()
 	for job := range jobs {
-		result := m.Do(job)
-		m.results <- result
+		m.Process(job)
 	}
 }
 
+// Abort cancels the requests in flight, they complete with a canceled
+// failure instead of waiting for their response.
+func (m *Monitor) Abort() {
+	m.cancel()
+}
+
 func (m *Monitor) Stop() {
+	m.cancel()
 	close(m.results)
 }
 
@@ -61,14 +196,17 @@ func (m Monitor) Results() <-chan Result {
 
 func New(client *http.Client, stamper func() time.Time) *Monitor {
 	var (
-		lock    = new(sync.Mutex)
-		results = make(chan Result, 100)
+		lock        = new(sync.Mutex)
+		results     = make(chan Result, 100)
+		ctx, cancel = context.WithCancel(context.Background())
 	)
 	monitor := Monitor{
 		lock:    lock,
 		stamper: stamper,
 		client:  client,
 		results: results,
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 	return &monitor
 }
@@ -1,11 +1,13 @@
 package monitor_test
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ksahli/baal/pkg/monitor"
 )
@@ -35,9 +37,11 @@ func TestDo(t *testing.T) {
 	got := sut.Do(job)
 	want := monitor.Result{
 		Location:  location,
+		Method:    "GET",
 		Status:    200,
 		Reachable: true,
 		Time:      timestamp,
+		Passed:    true,
 	}
 
 	if !reflect.DeepEqual(want, got) {
@@ -64,9 +68,12 @@ func TestDoError(t *testing.T) {
 	got := sut.Do(job)
 	want := monitor.Result{
 		Location:  location,
+		Method:    "GET",
 		Status:    0,
 		Reachable: false,
 		Time:      timestamp,
+		Failure:   monitor.InvalidRequest,
+		Error:     `Get "0.0.0.0": unsupported protocol scheme ""`,
 	}
 
 	if !reflect.DeepEqual(want, got) {
@@ -74,3 +81,213 @@ func TestDoError(t *testing.T) {
 		t.Fatalf(msg, want, got)
 	}
 }
+
+func TestDoTimings(t *testing.T) {
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}
+	handler := http.HandlerFunc(handle)
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	client := server.Client()
+	sut := monitor.New(client, clock(time.Millisecond))
+
+	location, err := url.Parse(server.URL)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	job := monitor.Job{
+		Location: location,
+		Method:   "GET",
+	}
+
+	got := sut.Do(job).Timings
+	if got.Connect <= 0 {
+		msg := "want a connect duration, got %v"
+		t.Fatalf(msg, got.Connect)
+	}
+	if got.TLS <= 0 {
+		msg := "want a TLS handshake duration, got %v"
+		t.Fatalf(msg, got.TLS)
+	}
+	if got.FirstByte <= got.Connect+got.TLS {
+		msg := "want first byte after connect and TLS handshake, got %v"
+		t.Fatalf(msg, got.FirstByte)
+	}
+	if got.Total < got.FirstByte {
+		msg := "want total duration after first byte, got %v"
+		t.Fatalf(msg, got.Total)
+	}
+}
+
+func TestDoRequest(t *testing.T) {
+	tests := map[string]struct {
+		credentials   monitor.Credentials
+		authorization string
+	}{
+		"anonymous": {
+			authorization: "",
+		},
+		"basic": {
+			credentials:   monitor.Basic{Username: "user", Password: "secret"},
+			authorization: "Basic dXNlcjpzZWNyZXQ=",
+		},
+		"bearer": {
+			credentials:   monitor.Bearer("token"),
+			authorization: "Bearer token",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var method, header, authorization, body string
+			handle := func(w http.ResponseWriter, r *http.Request) {
+				content, _ := io.ReadAll(r.Body)
+				method, body = r.Method, string(content)
+				header, authorization = r.Header.Get("X-Probe"), r.Header.Get("Authorization")
+				w.WriteHeader(204)
+			}
+			handler := http.HandlerFunc(handle)
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			client := new(http.Client)
+			sut := monitor.New(client, stamper)
+
+			location, err := url.Parse(server.URL)
+			if err != nil {
+				msg := "unwanted error %v"
+				t.Fatalf(msg, err)
+			}
+
+			job := monitor.Job{
+				Location:    location,
+				Method:      "POST",
+				Header:      http.Header{"X-Probe": []string{"baal"}},
+				Body:        []byte(`{"ping": true}`),
+				Credentials: test.credentials,
+			}
+
+			got := sut.Do(job)
+			if got.Status != 204 {
+				msg := "want status 204, got %d (%s)"
+				t.Fatalf(msg, got.Status, got.Error)
+			}
+			if method != "POST" {
+				msg := "want method POST, got %s"
+				t.Fatalf(msg, method)
+			}
+			if header != "baal" {
+				msg := "want header baal, got %q"
+				t.Fatalf(msg, header)
+			}
+			if body != `{"ping": true}` {
+				msg := "want the job body, got %q"
+				t.Fatalf(msg, body)
+			}
+			if authorization != test.authorization {
+				msg := "want authorization %q, got %q"
+				t.Fatalf(msg, test.authorization, authorization)
+			}
+		})
+	}
+}
+
+func TestDoTimeout(t *testing.T) {
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	handler := http.HandlerFunc(handle)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := new(http.Client)
+	sut := monitor.New(client, stamper)
+
+	location, err := url.Parse(server.URL)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	job := monitor.Job{
+		Location: location,
+		Method:   "GET",
+		Timeout:  10 * time.Millisecond,
+	}
+
+	got := sut.Do(job)
+	if got.Reachable {
+		t.Fatal("want an unreachable result, got a reachable one")
+	}
+	if got.Failure != monitor.Timeout {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, monitor.Timeout, got.Failure)
+	}
+}
+
+func TestDoTimeoutLonger(t *testing.T) {
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}
+	handler := http.HandlerFunc(handle)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+	sut := monitor.New(client, stamper)
+
+	location, err := url.Parse(server.URL)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	job := monitor.Job{
+		Location: location,
+		Method:   "GET",
+		Timeout:  time.Second,
+	}
+
+	got := sut.Do(job)
+	if !got.Reachable || got.Failure != "" {
+		msg := "want a reachable result within the timeout of the job, got %+v"
+		t.Fatalf(msg, got)
+	}
+}
+
+func TestDoAbort(t *testing.T) {
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}
+	handler := http.HandlerFunc(handle)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := new(http.Client)
+	sut := monitor.New(client, stamper)
+
+	location, err := url.Parse(server.URL)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	job := monitor.Job{
+		Location: location,
+		Method:   "GET",
+	}
+
+	time.AfterFunc(50*time.Millisecond, sut.Abort)
+
+	got := sut.Do(job)
+	if got.Reachable {
+		t.Fatal("want an unreachable result, got a reachable one")
+	}
+	if got.Failure != monitor.Canceled {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, monitor.Canceled, got.Failure)
+	}
+}
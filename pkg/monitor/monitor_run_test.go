@@ -54,9 +54,11 @@ func TestRun(t *testing.T) {
 
 		result := monitor.Result{
 			Location:  location,
+			Method:    "GET",
 			Status:    200,
 			Reachable: true,
 			Time:      timestamp,
+			Passed:    true,
 		}
 
 		want[location] = result
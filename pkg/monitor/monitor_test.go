@@ -1,6 +1,7 @@
 package monitor_test
 
 import (
+	"sync"
 	"time"
 )
 
@@ -9,3 +10,13 @@ var timestamp = time.Now().UTC()
 func stamper() time.Time {
 	return timestamp
 }
+
+func clock(step time.Duration) func() time.Time {
+	lock, now := new(sync.Mutex), timestamp
+	return func() time.Time {
+		lock.Lock()
+		defer lock.Unlock()
+		now = now.Add(step)
+		return now
+	}
+}
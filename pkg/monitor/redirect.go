@@ -0,0 +1,70 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	FollowRedirects = 0
+	NoRedirects     = -1
+
+	defaultRedirects = 10
+)
+
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+type Hop struct {
+	URL      string
+	Status   int
+	Location string
+}
+
+type redirects struct {
+	lock *sync.Mutex
+	max  int
+	hops []Hop
+}
+
+func (r *redirects) check(request *http.Request, via []*http.Request) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	hop := Hop{
+		URL:      via[len(via)-1].URL.String(),
+		Location: request.URL.String(),
+	}
+	if request.Response != nil {
+		hop.Status = request.Response.StatusCode
+	}
+	r.hops = append(r.hops, hop)
+
+	// The redirect not followed is still recorded, it tells where the
+	// response points to.
+	if r.max == NoRedirects {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > r.max {
+		return fmt.Errorf("stopped after %d redirects: %w", r.max, ErrTooManyRedirects)
+	}
+	return nil
+}
+
+func (r *redirects) recorded() []Hop {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.hops
+}
+
+func newRedirects(max int) *redirects {
+	if max == FollowRedirects {
+		max = defaultRedirects
+	}
+	redirects := redirects{
+		lock: new(sync.Mutex),
+		max:  max,
+	}
+	return &redirects
+}
@@ -0,0 +1,95 @@
+package monitor_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+func TestDoRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/a", http.RedirectHandler("/b", http.StatusMovedPermanently))
+	mux.Handle("/b", http.RedirectHandler("/c", http.StatusFound))
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	location, err := url.Parse(server.URL + "/a")
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	hops := []monitor.Hop{
+		{
+			URL:      server.URL + "/a",
+			Status:   http.StatusMovedPermanently,
+			Location: server.URL + "/b",
+		},
+		{
+			URL:      server.URL + "/b",
+			Status:   http.StatusFound,
+			Location: server.URL + "/c",
+		},
+	}
+
+	tests := map[string]struct {
+		max     int
+		status  int
+		failure monitor.Failure
+		hops    []monitor.Hop
+	}{
+		"follow": {
+			max:    monitor.FollowRedirects,
+			status: 200,
+			hops:   hops,
+		},
+		"none": {
+			max:    monitor.NoRedirects,
+			status: http.StatusMovedPermanently,
+			hops:   hops[:1],
+		},
+		"enough": {
+			max:    2,
+			status: 200,
+			hops:   hops,
+		},
+		"too many": {
+			max:     1,
+			failure: monitor.TooManyRedirects,
+			hops:    hops,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := new(http.Client)
+			sut := monitor.New(client, stamper)
+
+			job := monitor.Job{
+				Location:     location,
+				Method:       "GET",
+				MaxRedirects: test.max,
+			}
+
+			got := sut.Do(job)
+			if got.Status != test.status {
+				msg := "want status %d, got %d"
+				t.Fatalf(msg, test.status, got.Status)
+			}
+			if got.Failure != test.failure {
+				msg := "want failure %q, got %q"
+				t.Fatalf(msg, test.failure, got.Failure)
+			}
+			if !reflect.DeepEqual(test.hops, got.Redirects) {
+				msg := "\n want %v\n got  %v"
+				t.Fatalf(msg, test.hops, got.Redirects)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+type Timings struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+type trace struct {
+	lock    *sync.Mutex
+	stamper func() time.Time
+
+	start, dns, connect, tls time.Time
+	timings                  Timings
+}
+
+func (t *trace) since(start time.Time) time.Duration {
+	if start.IsZero() {
+		return 0
+	}
+	return t.stamper().Sub(start)
+}
+
+func (t *trace) client() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.dns = t.stamper()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.timings.DNS = t.since(t.dns)
+		},
+		ConnectStart: func(string, string) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.connect = t.stamper()
+		},
+		ConnectDone: func(string, string, error) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.timings.Connect = t.since(t.connect)
+		},
+		TLSHandshakeStart: func() {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.tls = t.stamper()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.timings.TLS = t.since(t.tls)
+		},
+		GotFirstResponseByte: func() {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.timings.FirstByte = t.since(t.start)
+		},
+	}
+}
+
+func (t *trace) done() Timings {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.timings.Total = t.since(t.start)
+	return t.timings
+}
+
+func newTrace(stamper func() time.Time) *trace {
+	trace := trace{
+		lock:    new(sync.Mutex),
+		stamper: stamper,
+		start:   stamper(),
+	}
+	return &trace
+}
@@ -0,0 +1,264 @@
+package notifier
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/ksahli/baal/pkg/loader"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+const (
+	defaultRetries = 3
+	defaultBackoff = time.Second
+	defaultTimeout = 10 * time.Second
+)
+
+type Config struct {
+	Webhooks []WebhookConfig `json:"webhooks"`
+	Mails    []MailConfig    `json:"smtp"`
+}
+
+type WebhookConfig struct {
+	Name     string            `json:"name"`
+	URL      string            `json:"url"`
+	Template string            `json:"template"`
+	Headers  map[string]string `json:"headers"`
+	Targets  []string          `json:"targets"`
+	Events   []string          `json:"events"`
+	Retries  *int              `json:"retries"`
+	Backoff  string            `json:"backoff"`
+	Timeout  string            `json:"timeout"`
+}
+
+type MailConfig struct {
+	Name     string        `json:"name"`
+	Address  string        `json:"address"`
+	From     string        `json:"from"`
+	To       []string      `json:"to"`
+	Username string        `json:"username"`
+	Password loader.Secret `json:"password"`
+	StartTLS string        `json:"starttls"`
+	Subject  string        `json:"subject"`
+	Body     string        `json:"body"`
+	Targets  []string      `json:"targets"`
+	Events   []string      `json:"events"`
+	Retries  *int          `json:"retries"`
+	Backoff  string        `json:"backoff"`
+	Timeout  string        `json:"timeout"`
+}
+
+func (c Config) Routes() ([]Route, error) {
+	routes := make([]Route, 0, len(c.Webhooks)+len(c.Mails))
+	for index, webhook := range c.Webhooks {
+		name := webhook.Name
+		if name == "" {
+			name = fmt.Sprintf("webhook %d", index)
+		}
+		route, err := webhook.route(name)
+		if err != nil {
+			err := fmt.Errorf("notifier error: %s: %w", name, err)
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	for index, mail := range c.Mails {
+		name := mail.Name
+		if name == "" {
+			name = fmt.Sprintf("smtp %d", index)
+		}
+		route, err := mail.route(name)
+		if err != nil {
+			err := fmt.Errorf("notifier error: %s: %w", name, err)
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func (w WebhookConfig) route(name string) (Route, error) {
+	location, err := url.Parse(w.URL)
+	switch {
+	case err != nil:
+		return Route{}, err
+	case location.Scheme != "http" && location.Scheme != "https":
+		err := fmt.Errorf("url %q is not http or https", w.URL)
+		return Route{}, err
+	}
+
+	webhook := Webhook{
+		URL:     w.URL,
+		Retries: defaultRetries,
+		Backoff: defaultBackoff,
+		Client:  &http.Client{Timeout: defaultTimeout},
+	}
+	if w.Template != "" {
+		if webhook.Template, err = Template(w.Template); err != nil {
+			return Route{}, err
+		}
+	}
+	if len(w.Headers) > 0 {
+		webhook.Header = http.Header{}
+		for name, value := range w.Headers {
+			webhook.Header.Set(name, value)
+		}
+	}
+	if w.Retries != nil {
+		if *w.Retries < 0 {
+			err := fmt.Errorf("negative retries %d", *w.Retries)
+			return Route{}, err
+		}
+		webhook.Retries = *w.Retries
+	}
+	if w.Backoff != "" {
+		if webhook.Backoff, err = loader.Duration(w.Backoff); err != nil {
+			return Route{}, err
+		}
+	}
+	if w.Timeout != "" {
+		if webhook.Client.Timeout, err = loader.Duration(w.Timeout); err != nil {
+			return Route{}, err
+		}
+	}
+
+	route := Route{Name: name, Sender: webhook}
+	if route.Targets, err = targets(w.Targets); err != nil {
+		return Route{}, err
+	}
+	if route.Events, err = events(w.Events); err != nil {
+		return Route{}, err
+	}
+	return route, nil
+}
+
+func (m MailConfig) route(name string) (Route, error) {
+	if _, _, err := net.SplitHostPort(m.Address); err != nil {
+		err := fmt.Errorf("invalid address %q", m.Address)
+		return Route{}, err
+	}
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		err := fmt.Errorf("invalid sender %q", m.From)
+		return Route{}, err
+	}
+	for _, recipient := range m.To {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			err := fmt.Errorf("invalid recipient %q", recipient)
+			return Route{}, err
+		}
+	}
+
+	sender := Mail{
+		Address:    m.Address,
+		From:       m.From,
+		To:         m.To,
+		Username:   m.Username,
+		Encryption: Opportunistic,
+		Retries:    defaultRetries,
+		Backoff:    defaultBackoff,
+		Timeout:    defaultTimeout,
+	}
+	switch encryption := Encryption(m.StartTLS); encryption {
+	case "":
+	case Opportunistic, Required, Disabled:
+		sender.Encryption = encryption
+	default:
+		err := fmt.Errorf("unknown starttls mode %q", m.StartTLS)
+		return Route{}, err
+	}
+
+	var err error
+	if m.Username != "" {
+		if sender.Password, err = m.Password.Resolve(); err != nil {
+			err := fmt.Errorf("password: %w", err)
+			return Route{}, err
+		}
+	}
+	if m.Subject != "" {
+		if sender.Subject, err = Template(m.Subject); err != nil {
+			return Route{}, err
+		}
+	}
+	if m.Body != "" {
+		if sender.Body, err = Template(m.Body); err != nil {
+			return Route{}, err
+		}
+	}
+	if m.Retries != nil {
+		if *m.Retries < 0 {
+			err := fmt.Errorf("negative retries %d", *m.Retries)
+			return Route{}, err
+		}
+		sender.Retries = *m.Retries
+	}
+	if m.Backoff != "" {
+		if sender.Backoff, err = loader.Duration(m.Backoff); err != nil {
+			return Route{}, err
+		}
+	}
+	if m.Timeout != "" {
+		if sender.Timeout, err = loader.Duration(m.Timeout); err != nil {
+			return Route{}, err
+		}
+	}
+
+	route := Route{Name: name, Sender: sender}
+	if route.Targets, err = targets(m.Targets); err != nil {
+		return Route{}, err
+	}
+	if route.Events, err = events(m.Events); err != nil {
+		return Route{}, err
+	}
+	return route, nil
+}
+
+func targets(patterns []string) ([]*regexp.Regexp, error) {
+	targets := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		target, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func events(names []string) ([]tracker.EventType, error) {
+	events := make([]tracker.EventType, 0, len(names))
+	for _, name := range names {
+		event := tracker.EventType(name)
+		switch event {
+		case tracker.DownEvent, tracker.DegradedEvent, tracker.RecoveredEvent, tracker.FlappingEvent:
+		default:
+			err := fmt.Errorf("unknown event %q", name)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func File(path string, format loader.Format) (Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		err := fmt.Errorf("notifier error: %w", err)
+		return Config{}, err
+	}
+	defer file.Close()
+	if format == nil {
+		format = loader.Detect(path)
+	}
+	config := Config{}
+	if err := format.Decode(file, &config); err != nil {
+		err := fmt.Errorf("notifier error: %w", err)
+		return Config{}, err
+	}
+	return config, nil
+}
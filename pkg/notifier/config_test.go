@@ -0,0 +1,113 @@
+package notifier_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/notifier"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+func TestFile(t *testing.T) {
+	for _, path := range []string{"testdata/notifiers.yaml", "testdata/notifiers.toml"} {
+		t.Run(path, func(t *testing.T) {
+			t.Setenv("BAAL_SMTP_PASSWORD", "secret")
+			config, err := notifier.File(path, nil)
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			routes, err := config.Routes()
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			if len(routes) != 3 {
+				msg := "want 3 routes, got %d"
+				t.Fatalf(msg, len(routes))
+			}
+
+			slack, api, mail := routes[0], routes[1], routes[2]
+			if want := []tracker.EventType{tracker.DownEvent, tracker.RecoveredEvent}; slack.Name != "slack" || !reflect.DeepEqual(want, slack.Events) {
+				msg := "want the slack route for %v, got %+v"
+				t.Fatalf(msg, want, slack)
+			}
+			if webhook := slack.Sender.(notifier.Webhook); webhook.Template == nil || webhook.Retries != 3 {
+				msg := "want a templated webhook with default retries, got %+v"
+				t.Fatalf(msg, webhook)
+			}
+
+			webhook := api.Sender.(notifier.Webhook)
+			if len(api.Targets) != 1 || !api.Targets[0].MatchString("https://api.domain.com") {
+				msg := "want the api targets, got %v"
+				t.Fatalf(msg, api.Targets)
+			}
+			if webhook.Retries != 0 || webhook.Backoff != 5*time.Second || webhook.Client.Timeout != 2*time.Second {
+				msg := "want the configured delivery, got %+v"
+				t.Fatalf(msg, webhook)
+			}
+			if webhook.Header.Get("Authorization") != "Bearer secret" {
+				msg := "want the configured headers, got %v"
+				t.Fatalf(msg, webhook.Header)
+			}
+
+			sender := mail.Sender.(notifier.Mail)
+			if mail.Name != "ops-mail" || sender.Address != "smtp.domain.com:587" || sender.Password != "secret" {
+				msg := "want the ops mail with its resolved password, got %+v"
+				t.Fatalf(msg, sender)
+			}
+			if sender.Encryption != notifier.Required || sender.Subject == nil || sender.Body != nil {
+				msg := "want required starttls and a templated subject, got %+v"
+				t.Fatalf(msg, sender)
+			}
+		})
+	}
+}
+
+func TestFileError(t *testing.T) {
+	if _, err := notifier.File("testdata/missing.yaml", nil); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+}
+
+func TestMailRoutesError(t *testing.T) {
+	tests := map[string]notifier.MailConfig{
+		"address":   {Address: "smtp.domain.com", From: "baal@domain.com"},
+		"sender":    {Address: "smtp.domain.com:25", From: "baal"},
+		"recipient": {Address: "smtp.domain.com:25", From: "baal@domain.com", To: []string{"ops"}},
+		"starttls":  {Address: "smtp.domain.com:25", From: "baal@domain.com", StartTLS: "always"},
+		"password":  {Address: "smtp.domain.com:25", From: "baal@domain.com", Username: "baal"},
+		"subject":   {Address: "smtp.domain.com:25", From: "baal@domain.com", Subject: "{{"},
+		"events":    {Address: "smtp.domain.com:25", From: "baal@domain.com", Events: []string{"stable"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := notifier.Config{Mails: []notifier.MailConfig{test}}
+			if _, err := config.Routes(); err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+		})
+	}
+}
+
+func TestRoutesError(t *testing.T) {
+	negative := -1
+	tests := map[string]notifier.WebhookConfig{
+		"scheme":   {URL: "ftp://domain.com"},
+		"template": {URL: "https://domain.com", Template: "{{"},
+		"retries":  {URL: "https://domain.com", Retries: &negative},
+		"backoff":  {URL: "https://domain.com", Backoff: "soon"},
+		"timeout":  {URL: "https://domain.com", Timeout: "-1s"},
+		"targets":  {URL: "https://domain.com", Targets: []string{"("}},
+		"events":   {URL: "https://domain.com", Events: []string{"up"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := notifier.Config{Webhooks: []notifier.WebhookConfig{test}}
+			if _, err := config.Routes(); err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+type Incident struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+}
+
+type Message struct {
+	Type       tracker.EventType `json:"type"`
+	Target     string            `json:"target"`
+	Method     string            `json:"method,omitempty"`
+	Time       time.Time         `json:"time"`
+	Status     int               `json:"status,omitempty"`
+	Failure    monitor.Failure   `json:"failure,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Violations []string          `json:"violations,omitempty"`
+	Incident   Incident          `json:"incident"`
+	Reminder   bool              `json:"reminder,omitempty"`
+	Text       string            `json:"text"`
+}
+
+func NewMessage(event tracker.Event) Message {
+	message := Message{
+		Type:       event.Type,
+		Target:     event.Target,
+		Method:     event.Result.Method,
+		Time:       event.Time,
+		Status:     event.Result.Status,
+		Failure:    event.Result.Failure,
+		Error:      event.Result.Error,
+		Violations: event.Result.Violations,
+		Incident:   Incident{Start: event.Incident.Start, End: event.Incident.End},
+		Reminder:   event.Reminder,
+	}
+	switch {
+	case event.Type == tracker.RecoveredEvent:
+		message.Incident.Duration = event.Incident.Duration.String()
+		message.Text = fmt.Sprintf("%s recovered after %s", event.Target, event.Incident.Duration)
+	case event.Type == tracker.FlappingEvent:
+		message.Text = fmt.Sprintf("%s is flapping: %d state changes, notifications paused until it settles", event.Target, event.Changes)
+	case event.Reminder:
+		since := event.Time.Sub(event.Incident.Start).Round(time.Second)
+		message.Text = fmt.Sprintf("%s is still %s after %s: %s", event.Target, event.Type, since, describe(event.Result))
+	default:
+		message.Text = fmt.Sprintf("%s is %s: %s", event.Target, event.Type, describe(event.Result))
+	}
+	return message
+}
+
+func describe(result monitor.Result) string {
+	switch {
+	case result.Error != "":
+		return result.Error
+	case len(result.Violations) > 0:
+		return strings.Join(result.Violations, "; ")
+	}
+	return fmt.Sprintf("status %d", result.Status)
+}
+
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Addressed is a sender that also delivers to the recipients of the
+// definition the message is about, such as mails. The other senders never
+// see them.
+type Addressed interface {
+	Sender
+	SendTo(ctx context.Context, message Message, recipients []string) error
+}
+
+type Route struct {
+	Name    string
+	Sender  Sender
+	Targets []*regexp.Regexp
+	Events  []tracker.EventType
+}
+
+func (r Route) matches(event tracker.Event) bool {
+	if len(r.Events) > 0 && !contains(r.Events, event.Type) {
+		return false
+	}
+	if len(r.Targets) == 0 {
+		return true
+	}
+	for _, target := range r.Targets {
+		if target.MatchString(event.Target) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(types []tracker.EventType, wanted tracker.EventType) bool {
+	for _, t := range types {
+		if t == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+type route struct {
+	Route
+	queue chan delivery
+}
+
+type delivery struct {
+	message    Message
+	recipients []string
+}
+
+type Notifier struct {
+	logger *log.Logger
+	routes []route
+
+	lock       *sync.Mutex
+	recipients map[string][]string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (n *Notifier) Start(wg *sync.WaitGroup) {
+	for _, r := range n.routes {
+		wg.Add(1)
+		go n.deliver(wg, r)
+	}
+}
+
+// Notify queues the event for every matching route without waiting for its
+// delivery. Events are dropped when a route is too far behind, and muted
+// during maintenance windows.
+func (n *Notifier) Notify(event tracker.Event) {
+	if event.Maintenance {
+		return
+	}
+	message := NewMessage(event)
+	n.lock.Lock()
+	recipients := n.recipients[event.Key]
+	n.lock.Unlock()
+	for _, r := range n.routes {
+		if !r.matches(event) {
+			continue
+		}
+		delivery := delivery{message: message}
+		if _, ok := r.Sender.(Addressed); ok {
+			delivery.recipients = recipients
+		}
+		select {
+		case r.queue <- delivery:
+		default:
+			n.logger.Printf("notifier %s: queue full, dropping %s event for %s", r.Name, event.Type, event.Target)
+		}
+	}
+}
+
+// Update replaces the recipients of each target, keyed by job as
+// monitor.Key does, added to the ones of the routes sending mails.
+func (n *Notifier) Update(recipients map[string][]string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.recipients = recipients
+}
+
+// Close stops accepting events, the ones queued are still delivered.
+func (n *Notifier) Close() {
+	for _, r := range n.routes {
+		close(r.queue)
+	}
+}
+
+// Abort cancels the deliveries in flight and their retries, the messages
+// still queued fail straight away.
+func (n *Notifier) Abort() {
+	n.cancel()
+}
+
+func (n *Notifier) deliver(wg *sync.WaitGroup, r route) {
+	defer wg.Done()
+	for delivery := range r.queue {
+		message := delivery.message
+		var err error
+		if addressed, ok := r.Sender.(Addressed); ok {
+			err = addressed.SendTo(n.ctx, message, delivery.recipients)
+		} else {
+			err = r.Sender.Send(n.ctx, message)
+		}
+		if err != nil {
+			n.logger.Printf("notifier %s: %s event for %s not delivered: %v", r.Name, message.Type, message.Target, err)
+		}
+	}
+}
+
+func New(routes []Route, logger *log.Logger) *Notifier {
+	queued := make([]route, 0, len(routes))
+	for _, r := range routes {
+		queued = append(queued, route{Route: r, queue: make(chan delivery, 100)})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	notifier := Notifier{
+		logger:     logger,
+		routes:     queued,
+		lock:       new(sync.Mutex),
+		recipients: map[string][]string{},
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	return &notifier
+}
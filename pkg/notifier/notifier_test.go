@@ -0,0 +1,233 @@
+package notifier_test
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/notifier"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+type Sender struct {
+	lock     *sync.Mutex
+	messages []notifier.Message
+}
+
+func (s *Sender) Send(ctx context.Context, message notifier.Message) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func (s *Sender) targets() []string {
+	targets := []string{}
+	for _, message := range s.messages {
+		targets = append(targets, string(message.Type)+" "+message.Target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+func event(t *testing.T, kind tracker.EventType, target string) tracker.Event {
+	location, err := url.Parse(target)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	event := tracker.Event{
+		Type:   kind,
+		Target: target,
+		Key:    monitor.Key("GET", location),
+		Time:   time.Now(),
+		Result: monitor.Result{Location: location, Method: "GET", Failure: monitor.Timeout, Error: "timeout"},
+	}
+	return event
+}
+
+func TestNotify(t *testing.T) {
+	all, api, recoveries := &Sender{lock: new(sync.Mutex)}, &Sender{lock: new(sync.Mutex)}, &Sender{lock: new(sync.Mutex)}
+	routes := []notifier.Route{
+		{Name: "all", Sender: all},
+		{Name: "api", Sender: api, Targets: []*regexp.Regexp{regexp.MustCompile(`^https://api\.`)}},
+		{Name: "recoveries", Sender: recoveries, Events: []tracker.EventType{tracker.RecoveredEvent}},
+	}
+	logger := log.New(os.Stderr, " [notifier] ", log.Ldate)
+	sut := notifier.New(routes, logger)
+
+	wg := new(sync.WaitGroup)
+	sut.Start(wg)
+	sut.Notify(event(t, tracker.DownEvent, "https://api.domain.com"))
+	sut.Notify(event(t, tracker.DegradedEvent, "https://www.domain.com"))
+	sut.Notify(event(t, tracker.RecoveredEvent, "https://www.domain.com"))
+	sut.Close()
+	wg.Wait()
+
+	tests := map[string]struct {
+		sender *Sender
+		want   []string
+	}{
+		"all": {
+			sender: all,
+			want:   []string{"degraded https://www.domain.com", "down https://api.domain.com", "recovered https://www.domain.com"},
+		},
+		"api": {
+			sender: api,
+			want:   []string{"down https://api.domain.com"},
+		},
+		"recoveries": {
+			sender: recoveries,
+			want:   []string{"recovered https://www.domain.com"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.sender.targets(); !reflect.DeepEqual(test.want, got) {
+				msg := "\nwant %v\n  got %v"
+				t.Fatalf(msg, test.want, got)
+			}
+		})
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	down := event(t, tracker.DownEvent, "https://domain.com")
+	if got, want := notifier.NewMessage(down).Text, "https://domain.com is down: timeout"; got != want {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, want, got)
+	}
+
+	recovered := event(t, tracker.RecoveredEvent, "https://domain.com")
+	recovered.Incident.Duration = 90 * time.Second
+	message := notifier.NewMessage(recovered)
+	if got, want := message.Text, "https://domain.com recovered after 1m30s"; got != want {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, want, got)
+	}
+	if message.Incident.Duration != "1m30s" {
+		msg := "want the incident duration, got %+v"
+		t.Fatalf(msg, message.Incident)
+	}
+
+	reminder := event(t, tracker.DownEvent, "https://domain.com")
+	reminder.Reminder, reminder.Incident.Start = true, reminder.Time.Add(-time.Hour)
+	if got, want := notifier.NewMessage(reminder).Text, "https://domain.com is still down after 1h0m0s: timeout"; got != want {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, want, got)
+	}
+
+	flapping := event(t, tracker.FlappingEvent, "https://domain.com")
+	flapping.Changes = 5
+	if got, want := notifier.NewMessage(flapping).Text, "https://domain.com is flapping: 5 state changes, notifications paused until it settles"; got != want {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+// Mailer records the recipients of the definitions along with the
+// messages, as mails do.
+type Mailer struct {
+	Sender
+	recipients map[string][]string
+}
+
+func (m *Mailer) SendTo(ctx context.Context, message notifier.Message, recipients []string) error {
+	m.lock.Lock()
+	m.recipients[message.Method+" "+message.Target] = recipients
+	m.lock.Unlock()
+	return m.Send(ctx, message)
+}
+
+func TestNotifyRecipients(t *testing.T) {
+	mailer := &Mailer{Sender: Sender{lock: new(sync.Mutex)}, recipients: map[string][]string{}}
+	webhook := &Sender{lock: new(sync.Mutex)}
+	logger := log.New(os.Stderr, " [notifier] ", log.Ldate)
+	sut := notifier.New([]notifier.Route{{Name: "mail", Sender: mailer}, {Name: "webhook", Sender: webhook}}, logger)
+	sut.Update(map[string][]string{"GET https://api.domain.com": {"api@domain.com"}})
+
+	// Another method on the same location is another job, with recipients
+	// of its own.
+	post := event(t, tracker.DownEvent, "https://api.domain.com")
+	post.Key, post.Result.Method = monitor.Key("POST", post.Result.Location), "POST"
+
+	wg := new(sync.WaitGroup)
+	sut.Start(wg)
+	sut.Notify(event(t, tracker.DownEvent, "https://api.domain.com"))
+	sut.Notify(post)
+	sut.Notify(event(t, tracker.DownEvent, "https://www.domain.com"))
+	sut.Close()
+	wg.Wait()
+
+	want := map[string][]string{
+		"GET https://api.domain.com":  {"api@domain.com"},
+		"POST https://api.domain.com": nil,
+		"GET https://www.domain.com":  nil,
+	}
+	if !reflect.DeepEqual(want, mailer.recipients) {
+		msg := "\nwant %v\n  got %v"
+		t.Fatalf(msg, want, mailer.recipients)
+	}
+	if len(webhook.messages) != 3 {
+		msg := "want the 3 messages on the webhook, got %v"
+		t.Fatalf(msg, webhook.messages)
+	}
+}
+
+type Blocking struct{}
+
+func (Blocking) Send(ctx context.Context, message notifier.Message) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestAbort(t *testing.T) {
+	logger := log.New(os.Stderr, " [notifier] ", log.Ldate)
+	sut := notifier.New([]notifier.Route{{Name: "blocking", Sender: Blocking{}}}, logger)
+
+	wg := new(sync.WaitGroup)
+	sut.Start(wg)
+	sut.Notify(event(t, tracker.DownEvent, "https://domain.com"))
+	sut.Close()
+	sut.Abort()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("want the delivery aborted, still running")
+	}
+}
+
+func TestNotifyMaintenance(t *testing.T) {
+	sender := &Sender{lock: new(sync.Mutex)}
+	logger := log.New(os.Stderr, " [notifier] ", log.Ldate)
+	sut := notifier.New([]notifier.Route{{Name: "all", Sender: sender}}, logger)
+
+	muted := event(t, tracker.DownEvent, "https://api.domain.com")
+	muted.Maintenance = true
+
+	wg := new(sync.WaitGroup)
+	sut.Start(wg)
+	sut.Notify(muted)
+	sut.Notify(event(t, tracker.DownEvent, "https://www.domain.com"))
+	sut.Close()
+	wg.Wait()
+
+	if want, got := []string{"down https://www.domain.com"}, sender.targets(); !reflect.DeepEqual(want, got) {
+		msg := "\nwant %v\n  got %v"
+		t.Fatalf(msg, want, got)
+	}
+}
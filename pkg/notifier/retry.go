@@ -0,0 +1,44 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string {
+	return p.err.Error()
+}
+
+func (p permanent) Unwrap() error {
+	return p.err
+}
+
+// retry calls attempt until it succeeds, fails permanently or runs out of
+// retries, doubling the delay between attempts from backoff.
+func retry(ctx context.Context, retries int, backoff time.Duration, attempt func() error) error {
+	for i := 0; ; i++ {
+		err := attempt()
+		var failure permanent
+		switch {
+		case err == nil:
+			return nil
+		case errors.As(err, &failure):
+			return failure.err
+		case i >= retries:
+			return err
+		}
+
+		timer := time.NewTimer(backoff << i)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
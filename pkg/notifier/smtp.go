@@ -0,0 +1,253 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type Encryption string
+
+const (
+	Opportunistic Encryption = "opportunistic"
+	Required      Encryption = "required"
+	Disabled      Encryption = "disabled"
+)
+
+var (
+	subject = template.Must(Template(`[baal] {{.Target}} {{.Type}}`))
+	body    = template.Must(Template(`{{.Text}}
+
+Target: {{.Target}}
+Event:  {{.Type}}
+Time:   {{.Time.Format "2006-01-02T15:04:05Z07:00"}}
+{{- if .Incident.Duration}}
+Down:   {{.Incident.Duration}}
+{{- else}}
+Since:  {{.Incident.Start.Format "2006-01-02T15:04:05Z07:00"}}
+{{- end}}
+`))
+)
+
+type Mail struct {
+	Address  string
+	From     string
+	To       []string
+	Username string
+	Password string
+
+	Encryption Encryption
+	TLSConfig  *tls.Config
+
+	Subject *template.Template
+	Body    *template.Template
+
+	Retries int
+	Backoff time.Duration
+	Timeout time.Duration
+}
+
+// Send mails the message to the configured recipients.
+func (m Mail) Send(ctx context.Context, message Message) error {
+	return m.SendTo(ctx, message, nil)
+}
+
+// SendTo mails the message to the configured recipients and to the ones of
+// the definition it is about.
+func (m Mail) SendTo(ctx context.Context, message Message, to []string) error {
+	sender, err := envelope(m.From)
+	if err != nil {
+		err := fmt.Errorf("smtp error: %w", err)
+		return err
+	}
+	recipients, envelopes, err := m.recipients(to)
+	if err != nil {
+		err := fmt.Errorf("smtp error: %w", err)
+		return err
+	}
+	if len(recipients) == 0 {
+		err := fmt.Errorf("smtp error: no recipients for %s", message.Target)
+		return err
+	}
+	content, err := m.compose(message, recipients)
+	if err != nil {
+		err := fmt.Errorf("smtp error: %w", err)
+		return err
+	}
+	return retry(ctx, m.Retries, m.Backoff, func() error {
+		if err := m.deliver(ctx, sender, envelopes, content); err != nil {
+			return fmt.Errorf("smtp error: %w", err)
+		}
+		return nil
+	})
+}
+
+// recipients returns the addresses as written, for the To header, and their
+// bare form, for the envelope, once per mailbox.
+func (m Mail) recipients(to []string) ([]string, []string, error) {
+	recipients, envelopes, seen := []string{}, []string{}, map[string]bool{}
+	for _, recipient := range append(append([]string{}, m.To...), to...) {
+		address, err := envelope(recipient)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !seen[address] {
+			seen[address] = true
+			recipients = append(recipients, recipient)
+			envelopes = append(envelopes, address)
+		}
+	}
+	return recipients, envelopes, nil
+}
+
+// envelope returns the bare address of the MAIL and RCPT commands, a display
+// name only belongs to the headers.
+func envelope(address string) (string, error) {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		err := fmt.Errorf("invalid address %q", address)
+		return "", err
+	}
+	return parsed.Address, nil
+}
+
+func (m Mail) compose(message Message, recipients []string) ([]byte, error) {
+	subjectTemplate, bodyTemplate := m.Subject, m.Body
+	if subjectTemplate == nil {
+		subjectTemplate = subject
+	}
+	if bodyTemplate == nil {
+		bodyTemplate = body
+	}
+
+	title := new(bytes.Buffer)
+	if err := subjectTemplate.Execute(title, message); err != nil {
+		return nil, err
+	}
+	text := new(bytes.Buffer)
+	if err := bodyTemplate.Execute(text, message); err != nil {
+		return nil, err
+	}
+
+	header := strings.Join(strings.Fields(title.String()), " ")
+	content := new(bytes.Buffer)
+	fmt.Fprintf(content, "From: %s\r\n", m.From)
+	fmt.Fprintf(content, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(content, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", header))
+	fmt.Fprintf(content, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(content, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(content, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, line := range strings.Split(strings.TrimRight(text.String(), "\n"), "\n") {
+		fmt.Fprintf(content, "%s\r\n", strings.TrimRight(line, "\r"))
+	}
+	return content.Bytes(), nil
+}
+
+func (m Mail) deliver(ctx context.Context, sender string, recipients []string, content []byte) error {
+	host, _, err := net.SplitHostPort(m.Address)
+	if err != nil {
+		return permanent{err}
+	}
+
+	dialer := net.Dialer{Timeout: m.Timeout}
+	connection, err := dialer.DialContext(ctx, "tcp", m.Address)
+	if err != nil {
+		return err
+	}
+	if m.Timeout > 0 {
+		connection.SetDeadline(time.Now().Add(m.Timeout))
+	}
+	// The conversation ends with the connection once ctx is canceled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			connection.Close()
+		case <-done:
+		}
+	}()
+	client, err := smtp.NewClient(connection, host)
+	if err != nil {
+		connection.Close()
+		return err
+	}
+	defer client.Close()
+
+	if m.Encryption != Disabled {
+		ok, _ := client.Extension("STARTTLS")
+		switch {
+		case ok:
+			config := &tls.Config{ServerName: host}
+			if m.TLSConfig != nil {
+				config = m.TLSConfig.Clone()
+			}
+			if err := client.StartTLS(config); err != nil {
+				return permanent{err}
+			}
+		case m.Encryption == Required:
+			err := errors.New("server does not support STARTTLS")
+			return permanent{err}
+		}
+	}
+
+	if m.Username != "" {
+		if err := client.Auth(refusal{smtp.PlainAuth("", m.Username, m.Password, host)}); err != nil {
+			return rejected(err)
+		}
+	}
+	if err := client.Mail(sender); err != nil {
+		return rejected(err)
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return rejected(err)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return rejected(err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return rejected(err)
+	}
+	return client.Quit()
+}
+
+// refusal makes permanent the errors of the mechanism itself, such as PLAIN
+// refusing to send the password over an unencrypted connection: they are the
+// same on every attempt.
+type refusal struct {
+	smtp.Auth
+}
+
+func (r refusal) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	protocol, response, err := r.Auth.Start(server)
+	if err != nil {
+		return "", nil, permanent{err}
+	}
+	return protocol, response, nil
+}
+
+// rejected makes permanent the errors the server will answer again, leaving
+// transient 4xx replies and connection failures to be retried.
+func rejected(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return permanent{err}
+	}
+	return err
+}
@@ -0,0 +1,368 @@
+package notifier_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/notifier"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+type Mailbox struct {
+	From       string
+	Recipients []string
+	Data       string
+	TLS        bool
+	Auth       string
+}
+
+// Server is a minimal SMTP stand-in, it answers the commands of net/smtp
+// and records the mails it receives.
+type Server struct {
+	listener net.Listener
+	tls      *tls.Config
+	failures []string
+
+	lock  *sync.Mutex
+	mails []Mailbox
+}
+
+func (s *Server) serve() {
+	for {
+		connection, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(connection)
+	}
+}
+
+func (s *Server) session(connection net.Conn) {
+	defer connection.Close()
+	var (
+		reader = bufio.NewReader(connection)
+		mail   = Mailbox{}
+	)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(connection, format+"\r\n", args...)
+	}
+	reply("220 localhost ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch command {
+		case "EHLO":
+			reply("250-localhost")
+			if s.tls != nil && !mail.TLS {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			secured := tls.Server(connection, s.tls)
+			if err := secured.Handshake(); err != nil {
+				return
+			}
+			connection, reader, mail.TLS = secured, bufio.NewReader(secured), true
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.Fields(line)[2])
+			mail.Auth = strings.ReplaceAll(string(credentials), "\x00", " ")
+			reply("235 authenticated")
+		case "MAIL":
+			s.lock.Lock()
+			var failure string
+			if len(s.failures) > 0 {
+				failure, s.failures = s.failures[0], s.failures[1:]
+			}
+			s.lock.Unlock()
+			if failure != "" {
+				reply(failure)
+				continue
+			}
+			mail.From = strings.TrimSuffix(strings.TrimPrefix(line[len("MAIL FROM:"):], "<"), ">")
+			reply("250 ok")
+		case "RCPT":
+			mail.Recipients = append(mail.Recipients, strings.TrimSuffix(strings.TrimPrefix(line[len("RCPT TO:"):], "<"), ">"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			data := new(strings.Builder)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			mail.Data = data.String()
+			s.lock.Lock()
+			s.mails = append(s.mails, mail)
+			s.lock.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *Server) received() []Mailbox {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Mailbox(nil), s.mails...)
+}
+
+func server(t *testing.T, secure bool, failures ...string) (*Server, *tls.Config) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	server := Server{listener: listener, failures: failures, lock: new(sync.Mutex)}
+
+	var client *tls.Config
+	if secure {
+		https := httptest.NewTLSServer(nil)
+		https.Close()
+		server.tls = &tls.Config{Certificates: https.TLS.Certificates}
+		roots := x509.NewCertPool()
+		roots.AddCert(https.Certificate())
+		client = &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}
+	}
+
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return &server, client
+}
+
+var down = notifier.Message{
+	Type:     tracker.DownEvent,
+	Target:   "https://domain.com",
+	Time:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	Text:     "https://domain.com is down: timeout",
+	Incident: notifier.Incident{Start: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+}
+
+// owners are the recipients of the definition down is about.
+var owners = []string{"owner@domain.com", "ops@domain.com"}
+
+func TestMail(t *testing.T) {
+	server, config := server(t, true)
+
+	mail := notifier.Mail{
+		Address:    server.listener.Addr().String(),
+		From:       "baal@domain.com",
+		To:         []string{"ops@domain.com"},
+		Username:   "baal",
+		Password:   "secret",
+		Encryption: notifier.Required,
+		TLSConfig:  config,
+	}
+	if err := mail.SendTo(ctx, down, owners); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	mails := server.received()
+	if len(mails) != 1 {
+		msg := "want 1 mail, got %d"
+		t.Fatalf(msg, len(mails))
+	}
+	got := mails[0]
+	if !got.TLS || got.Auth != " baal secret" || got.From != "baal@domain.com" {
+		msg := "want an authenticated mail over TLS from baal, got %+v"
+		t.Fatalf(msg, got)
+	}
+	if want := []string{"ops@domain.com", "owner@domain.com"}; strings.Join(got.Recipients, " ") != strings.Join(want, " ") {
+		msg := "want recipients %v, got %v"
+		t.Fatalf(msg, want, got.Recipients)
+	}
+	for _, want := range []string{
+		"Subject: [baal] https://domain.com down\r\n",
+		"To: ops@domain.com, owner@domain.com\r\n",
+		"\r\nhttps://domain.com is down: timeout\r\n",
+		"Since:  2022-01-01T00:00:00Z\r\n",
+	} {
+		if !strings.Contains(got.Data, want) {
+			msg := "want %q in the mail, got\n%s"
+			t.Fatalf(msg, want, got.Data)
+		}
+	}
+}
+
+func TestMailTemplate(t *testing.T) {
+	server, _ := server(t, false)
+
+	subject, err := notifier.Template(`{{.Type}}: {{.Target}}`)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	body, err := notifier.Template(`{{.Text}}`)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	mail := notifier.Mail{
+		Address: server.listener.Addr().String(),
+		From:    "baal@domain.com",
+		Subject: subject,
+		Body:    body,
+	}
+	if err := mail.SendTo(ctx, down, owners); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	got := server.received()[0]
+	if got.TLS || got.Auth != "" {
+		msg := "want a plain unauthenticated mail, got %+v"
+		t.Fatalf(msg, got)
+	}
+	if !strings.Contains(got.Data, "Subject: down: https://domain.com\r\n") || !strings.HasSuffix(got.Data, "\r\n\r\nhttps://domain.com is down: timeout\r\n") {
+		msg := "want the templated mail, got\n%s"
+		t.Fatalf(msg, got.Data)
+	}
+}
+
+func TestMailError(t *testing.T) {
+	tests := map[string]struct {
+		failures   []string
+		encryption notifier.Encryption
+		recipients []string
+		retries    int
+	}{
+		"no recipients": {},
+		"starttls required": {
+			encryption: notifier.Required,
+			recipients: []string{"ops@domain.com"},
+		},
+		"rejected": {
+			failures:   []string{"550 sender rejected", "250 ok"},
+			recipients: []string{"ops@domain.com"},
+			retries:    3,
+		},
+		"unavailable": {
+			failures:   []string{"421 try later", "421 try later"},
+			recipients: []string{"ops@domain.com"},
+			retries:    1,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, _ := server(t, false, test.failures...)
+			mail := notifier.Mail{
+				Address:    server.listener.Addr().String(),
+				From:       "baal@domain.com",
+				To:         test.recipients,
+				Encryption: test.encryption,
+				Retries:    test.retries,
+				Backoff:    time.Millisecond,
+			}
+			if err := mail.Send(ctx, down); err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+			if got := len(server.received()); got != 0 {
+				msg := "want no mail delivered, got %d"
+				t.Fatalf(msg, got)
+			}
+		})
+	}
+}
+
+func TestMailRetry(t *testing.T) {
+	server, _ := server(t, false, "421 try later")
+	mail := notifier.Mail{
+		Address: server.listener.Addr().String(),
+		From:    "baal@domain.com",
+		To:      []string{"ops@domain.com"},
+		Retries: 1,
+		Backoff: time.Millisecond,
+	}
+	if err := mail.Send(ctx, down); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	if got := len(server.received()); got != 1 {
+		msg := "want 1 mail delivered, got %d"
+		t.Fatalf(msg, got)
+	}
+}
+
+func TestMailDisplayNames(t *testing.T) {
+	server, _ := server(t, false)
+	mail := notifier.Mail{
+		Address: server.listener.Addr().String(),
+		From:    "Baal <baal@domain.com>",
+		To:      []string{"Ops <ops@domain.com>"},
+	}
+	if err := mail.SendTo(ctx, down, owners); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	got := server.received()[0]
+	if got.From != "baal@domain.com" {
+		msg := "want the bare sender in the envelope, got %q"
+		t.Fatalf(msg, got.From)
+	}
+	if want := []string{"ops@domain.com", "owner@domain.com"}; strings.Join(got.Recipients, " ") != strings.Join(want, " ") {
+		msg := "want recipients %v, got %v"
+		t.Fatalf(msg, want, got.Recipients)
+	}
+	for _, want := range []string{
+		"From: Baal <baal@domain.com>\r\n",
+		"To: Ops <ops@domain.com>, owner@domain.com\r\n",
+	} {
+		if !strings.Contains(got.Data, want) {
+			msg := "want %q in the mail, got\n%s"
+			t.Fatalf(msg, want, got.Data)
+		}
+	}
+}
+
+func TestMailUnencryptedAuth(t *testing.T) {
+	// PLAIN only refuses to send the password to a server other than
+	// localhost.
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("no second loopback address: %v", err)
+	}
+	server := Server{listener: listener, lock: new(sync.Mutex)}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	mail := notifier.Mail{
+		Address:    listener.Addr().String(),
+		From:       "baal@domain.com",
+		To:         []string{"ops@domain.com"},
+		Username:   "baal",
+		Password:   "secret",
+		Encryption: notifier.Disabled,
+		Retries:    3,
+		Backoff:    time.Minute,
+	}
+	start := time.Now()
+	if err := mail.Send(ctx, down); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		msg := "want the refusal reported without retrying, got it after %s"
+		t.Fatalf(msg, elapsed)
+	}
+}
@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+var functions = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+}
+
+func Template(text string) (*template.Template, error) {
+	return template.New("payload").Funcs(functions).Parse(text)
+}
+
+type Webhook struct {
+	URL      string
+	Template *template.Template
+	Header   http.Header
+	Retries  int
+	Backoff  time.Duration
+	Client   *http.Client
+}
+
+func (w Webhook) Send(ctx context.Context, message Message) error {
+	payload, err := w.payload(message)
+	if err != nil {
+		err := fmt.Errorf("webhook error: %w", err)
+		return err
+	}
+	return retry(ctx, w.Retries, w.Backoff, func() error {
+		return w.post(ctx, payload)
+	})
+}
+
+func (w Webhook) payload(message Message) ([]byte, error) {
+	if w.Template == nil {
+		return json.Marshal(message)
+	}
+	buffer := new(bytes.Buffer)
+	if err := w.Template.Execute(buffer, message); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (w Webhook) post(ctx context.Context, payload []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return permanent{err}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, values := range w.Header {
+		request.Header[name] = values
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+
+	switch {
+	case response.StatusCode < 300:
+		return nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		err := fmt.Errorf("webhook error: status %d", response.StatusCode)
+		return err
+	}
+	err = fmt.Errorf("webhook error: status %d", response.StatusCode)
+	return permanent{err}
+}
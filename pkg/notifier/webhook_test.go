@@ -0,0 +1,168 @@
+package notifier_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/notifier"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+var ctx = context.Background()
+
+type Receiver struct {
+	lock     *sync.Mutex
+	statuses []int
+	payloads []string
+	headers  []http.Header
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	body, _ := io.ReadAll(request.Body)
+	r.payloads = append(r.payloads, string(body))
+	r.headers = append(r.headers, request.Header)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *Receiver) received() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.payloads...)
+}
+
+func receiver(statuses ...int) (*Receiver, *httptest.Server) {
+	receiver := Receiver{lock: new(sync.Mutex), statuses: statuses}
+	server := httptest.NewServer(&receiver)
+	return &receiver, server
+}
+
+var message = notifier.Message{
+	Type:   tracker.DownEvent,
+	Target: "https://domain.com",
+	Status: 503,
+	Text:   `https://domain.com is down: status 503 "unavailable"`,
+}
+
+func TestWebhook(t *testing.T) {
+	receiver, server := receiver()
+	defer server.Close()
+
+	template, err := notifier.Template(`{"text": {{json .Text}}}`)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	webhook := notifier.Webhook{
+		URL:      server.URL,
+		Template: template,
+		Header:   http.Header{"Authorization": {"Bearer secret"}},
+	}
+	if err := webhook.Send(ctx, message); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	want := `{"text": "https://domain.com is down: status 503 \"unavailable\""}`
+	if got := receiver.received(); len(got) != 1 || got[0] != want {
+		msg := "\nwant %s\n  got %v"
+		t.Fatalf(msg, want, got)
+	}
+	header := receiver.headers[0]
+	if header.Get("Authorization") != "Bearer secret" || header.Get("Content-Type") != "application/json" {
+		msg := "want the configured headers, got %v"
+		t.Fatalf(msg, header)
+	}
+}
+
+func TestWebhookDefaultPayload(t *testing.T) {
+	receiver, server := receiver()
+	defer server.Close()
+
+	webhook := notifier.Webhook{URL: server.URL}
+	if err := webhook.Send(ctx, message); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	got := notifier.Message{}
+	if err := json.Unmarshal([]byte(receiver.received()[0]), &got); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	if got.Type != message.Type || got.Target != message.Target || got.Status != message.Status {
+		msg := "\nwant %+v\n  got %+v"
+		t.Fatalf(msg, message, got)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	tests := map[string]struct {
+		statuses []int
+		retries  int
+		attempts int
+		fails    bool
+	}{
+		"recovers": {
+			statuses: []int{500, 429, 200},
+			retries:  3,
+			attempts: 3,
+		},
+		"gives up": {
+			statuses: []int{502, 502, 502},
+			retries:  2,
+			attempts: 3,
+			fails:    true,
+		},
+		"client error": {
+			statuses: []int{400, 200},
+			retries:  3,
+			attempts: 1,
+			fails:    true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			receiver, server := receiver(test.statuses...)
+			defer server.Close()
+
+			webhook := notifier.Webhook{
+				URL:     server.URL,
+				Retries: test.retries,
+				Backoff: time.Millisecond,
+			}
+			err := webhook.Send(ctx, message)
+			if fails := err != nil; fails != test.fails {
+				msg := "want failure %t, got %v"
+				t.Fatalf(msg, test.fails, err)
+			}
+			if got := len(receiver.received()); got != test.attempts {
+				msg := "want %d attempts, got %d"
+				t.Fatalf(msg, test.attempts, got)
+			}
+		})
+	}
+}
+
+func TestWebhookTemplateError(t *testing.T) {
+	template, err := notifier.Template(`{{.Missing}}`)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	webhook := notifier.Webhook{URL: "http://localhost", Template: template}
+	if err := webhook.Send(ctx, message); err == nil {
+		t.Fatal("want an error, got nothing")
+	}
+}
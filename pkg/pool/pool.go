@@ -0,0 +1,202 @@
+package pool
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+type Processor interface {
+	Process(job monitor.Job)
+}
+
+type Stats struct {
+	Workers     int
+	Busy        int
+	Waiting     int
+	Queued      int
+	Capacity    int
+	Utilisation float64
+	Dropped     int
+}
+
+type Pool struct {
+	busy, waiting, dropped, stopped int64
+
+	processor Processor
+	workers   int
+	limit     int
+	queue     chan monitor.Job
+
+	lock  *sync.Mutex
+	hosts map[string]*host
+}
+
+// host counts the jobs of a host in flight and keeps the ones waiting for
+// one of them to finish, so no worker ever blocks on a busy host.
+type host struct {
+	active  int
+	pending []monitor.Job
+}
+
+func (p *Pool) Start(wg *sync.WaitGroup) {
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go p.work(wg)
+	}
+}
+
+func (p *Pool) Feed(wg *sync.WaitGroup, jobs <-chan monitor.Job) {
+	defer wg.Done()
+	for job := range jobs {
+		if p.halted() {
+			atomic.AddInt64(&p.dropped, 1)
+			continue
+		}
+		p.queue <- job
+	}
+}
+
+// Stop keeps the jobs already processed running but drops the queued ones
+// and every job fed afterwards.
+func (p *Pool) Stop() {
+	atomic.StoreInt64(&p.stopped, 1)
+}
+
+func (p *Pool) Close() {
+	close(p.queue)
+}
+
+func (p *Pool) Stats() Stats {
+	var (
+		busy    = int(atomic.LoadInt64(&p.busy))
+		waiting = int(atomic.LoadInt64(&p.waiting))
+		dropped = int(atomic.LoadInt64(&p.dropped))
+	)
+	stats := Stats{
+		Workers:     p.workers,
+		Busy:        busy,
+		Waiting:     waiting,
+		Queued:      len(p.queue),
+		Capacity:    cap(p.queue),
+		Utilisation: float64(busy) / float64(p.workers),
+		Dropped:     dropped,
+	}
+	return stats
+}
+
+func (p *Pool) work(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for job := range p.queue {
+		if p.halted() {
+			atomic.AddInt64(&p.dropped, 1)
+			continue
+		}
+		if !p.acquire(job) {
+			continue
+		}
+		for {
+			atomic.AddInt64(&p.busy, 1)
+			p.processor.Process(job)
+			atomic.AddInt64(&p.busy, -1)
+
+			next, ok := p.release(job)
+			if !ok {
+				break
+			}
+			job = next
+		}
+	}
+}
+
+func (p *Pool) halted() bool {
+	return atomic.LoadInt64(&p.stopped) == 1
+}
+
+// acquire reports whether the job can run now. When its host is at the
+// limit the job waits for the host instead, and is dropped if the host
+// already has as many jobs waiting as the queue holds.
+func (p *Pool) acquire(job monitor.Job) bool {
+	if p.limit <= 0 {
+		return true
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	name := job.Location.Host
+	h, ok := p.hosts[name]
+	if !ok {
+		h = new(host)
+		p.hosts[name] = h
+	}
+	switch {
+	case h.active < p.limit:
+		h.active++
+		return true
+	case len(h.pending) >= p.backlog():
+		atomic.AddInt64(&p.dropped, 1)
+	default:
+		h.pending = append(h.pending, job)
+		atomic.AddInt64(&p.waiting, 1)
+	}
+	return false
+}
+
+func (p *Pool) backlog() int {
+	if capacity := cap(p.queue); capacity > 0 {
+		return capacity
+	}
+	return 1
+}
+
+// release hands the slot of the finished job to the next one waiting for
+// its host, if any, and frees it otherwise.
+func (p *Pool) release(job monitor.Job) (monitor.Job, bool) {
+	if p.limit <= 0 {
+		return monitor.Job{}, false
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	name := job.Location.Host
+	h := p.hosts[name]
+	if p.halted() {
+		atomic.AddInt64(&p.dropped, int64(len(h.pending)))
+		atomic.AddInt64(&p.waiting, -int64(len(h.pending)))
+		h.pending = nil
+	}
+	if len(h.pending) > 0 {
+		next := h.pending[0]
+		h.pending = h.pending[1:]
+		atomic.AddInt64(&p.waiting, -1)
+		return next, true
+	}
+	h.active--
+	if h.active == 0 {
+		delete(p.hosts, name)
+	}
+	return monitor.Job{}, false
+}
+
+func New(processor Processor, workers, limit, depth int) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	var (
+		lock  = new(sync.Mutex)
+		queue = make(chan monitor.Job, depth)
+		hosts = map[string]*host{}
+	)
+	pool := Pool{
+		processor: processor,
+		workers:   workers,
+		limit:     limit,
+		queue:     queue,
+		lock:      lock,
+		hosts:     hosts,
+	}
+	return &pool
+}
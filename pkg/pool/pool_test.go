@@ -0,0 +1,243 @@
+package pool_test
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/pool"
+)
+
+type Processor struct {
+	lock       *sync.Mutex
+	release    chan struct{}
+	slow       string
+	active     map[string]int
+	peak       map[string]int
+	total      int
+	maximum    int
+	concurrent int
+}
+
+func (p *Processor) Process(job monitor.Job) {
+	p.lock.Lock()
+	p.active[job.Location.Host]++
+	p.concurrent++
+	if p.active[job.Location.Host] > p.peak[job.Location.Host] {
+		p.peak[job.Location.Host] = p.active[job.Location.Host]
+	}
+	if p.concurrent > p.maximum {
+		p.maximum = p.concurrent
+	}
+	p.lock.Unlock()
+
+	if p.slow == "" || p.slow == job.Location.Host {
+		<-p.release
+	}
+
+	p.lock.Lock()
+	p.active[job.Location.Host]--
+	p.concurrent--
+	p.total++
+	p.lock.Unlock()
+}
+
+func processor() *Processor {
+	processor := Processor{
+		lock:    new(sync.Mutex),
+		release: make(chan struct{}),
+		active:  map[string]int{},
+		peak:    map[string]int{},
+	}
+	return &processor
+}
+
+func jobs(t *testing.T, hosts, count int) chan monitor.Job {
+	jobs := make(chan monitor.Job, hosts*count)
+	for i := 0; i < count; i++ {
+		for h := 0; h < hosts; h++ {
+			location, err := url.Parse(fmt.Sprintf("https://domain-%d.com/%d", h, i))
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			jobs <- monitor.Job{Location: location, Method: "GET"}
+		}
+	}
+	close(jobs)
+	return jobs
+}
+
+func eventually(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPool(t *testing.T) {
+	processor := processor()
+	sut := pool.New(processor, 3, 0, 10)
+
+	pwg, fwg := new(sync.WaitGroup), new(sync.WaitGroup)
+	sut.Start(pwg)
+
+	fwg.Add(2)
+	go sut.Feed(fwg, jobs(t, 2, 5))
+	go sut.Feed(fwg, jobs(t, 2, 5))
+
+	eventually(t, func() bool {
+		stats := sut.Stats()
+		return stats.Busy == 3 && stats.Queued > 0
+	})
+
+	stats := sut.Stats()
+	if stats.Workers != 3 || stats.Capacity != 10 || stats.Utilisation != 1 {
+		msg := "want 3 fully used workers and a capacity of 10, got %+v"
+		t.Fatalf(msg, stats)
+	}
+
+	close(processor.release)
+
+	fwg.Wait()
+	sut.Close()
+	pwg.Wait()
+
+	if processor.total != 20 {
+		msg := "want 20 processed jobs, got %d"
+		t.Fatalf(msg, processor.total)
+	}
+	if processor.maximum != 3 {
+		msg := "want at most 3 concurrent jobs, got %d"
+		t.Fatalf(msg, processor.maximum)
+	}
+	if stats := sut.Stats(); stats.Busy != 0 || stats.Queued != 0 {
+		msg := "want an idle pool, got %+v"
+		t.Fatalf(msg, stats)
+	}
+}
+
+func TestPoolHostLimit(t *testing.T) {
+	processor := processor()
+	sut := pool.New(processor, 4, 1, 10)
+
+	pwg, fwg := new(sync.WaitGroup), new(sync.WaitGroup)
+	sut.Start(pwg)
+
+	fwg.Add(1)
+	go sut.Feed(fwg, jobs(t, 2, 4))
+
+	eventually(t, func() bool {
+		stats := sut.Stats()
+		return stats.Busy == 2 && stats.Waiting == 6 && stats.Queued == 0
+	})
+
+	close(processor.release)
+
+	fwg.Wait()
+	sut.Close()
+	pwg.Wait()
+
+	if processor.total != 8 {
+		msg := "want 8 processed jobs, got %d"
+		t.Fatalf(msg, processor.total)
+	}
+	for host, peak := range processor.peak {
+		if peak != 1 {
+			msg := "want at most 1 concurrent job for %s, got %d"
+			t.Fatalf(msg, host, peak)
+		}
+	}
+}
+
+func TestPoolSaturatedHost(t *testing.T) {
+	processor := processor()
+	processor.slow = "domain-0.com"
+	sut := pool.New(processor, 2, 1, 10)
+
+	pwg, fwg := new(sync.WaitGroup), new(sync.WaitGroup)
+	sut.Start(pwg)
+
+	// A burst for the slow host comes first, the idle host must not wait
+	// behind it.
+	fwg.Add(2)
+	sut.Feed(fwg, jobs(t, 1, 5))
+	go sut.Feed(fwg, idle(t, 3))
+
+	eventually(t, func() bool {
+		processor.lock.Lock()
+		defer processor.lock.Unlock()
+		return processor.total == 3
+	})
+	if stats := sut.Stats(); stats.Busy != 1 || stats.Waiting != 4 {
+		msg := "want 1 busy worker and 4 jobs waiting for the slow host, got %+v"
+		t.Fatalf(msg, stats)
+	}
+
+	close(processor.release)
+
+	fwg.Wait()
+	sut.Close()
+	pwg.Wait()
+
+	if processor.total != 8 {
+		msg := "want 8 processed jobs, got %d"
+		t.Fatalf(msg, processor.total)
+	}
+	if stats := sut.Stats(); stats.Waiting != 0 || stats.Dropped != 0 {
+		msg := "want no job left waiting or dropped, got %+v"
+		t.Fatalf(msg, stats)
+	}
+}
+
+func idle(t *testing.T, count int) chan monitor.Job {
+	jobs := make(chan monitor.Job, count)
+	for i := 0; i < count; i++ {
+		location, err := url.Parse(fmt.Sprintf("https://idle.com/%d", i))
+		if err != nil {
+			msg := "unwanted error: %v"
+			t.Fatalf(msg, err)
+		}
+		jobs <- monitor.Job{Location: location, Method: "GET"}
+	}
+	close(jobs)
+	return jobs
+}
+
+func TestPoolStop(t *testing.T) {
+	processor := processor()
+	sut := pool.New(processor, 2, 0, 10)
+
+	pwg, fwg := new(sync.WaitGroup), new(sync.WaitGroup)
+	sut.Start(pwg)
+
+	fwg.Add(1)
+	go sut.Feed(fwg, jobs(t, 2, 5))
+
+	eventually(t, func() bool {
+		stats := sut.Stats()
+		return stats.Busy == 2
+	})
+
+	sut.Stop()
+	close(processor.release)
+
+	fwg.Wait()
+	sut.Close()
+	pwg.Wait()
+
+	if processor.total != 2 {
+		msg := "want the 2 jobs in flight processed, got %d"
+		t.Fatalf(msg, processor.total)
+	}
+	if stats := sut.Stats(); stats.Dropped != 8 {
+		msg := "want 8 dropped jobs, got %+v"
+		t.Fatalf(msg, stats)
+	}
+}
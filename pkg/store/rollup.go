@@ -0,0 +1,110 @@
+package store
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+type Latency struct {
+	Min time.Duration `json:"min"`
+	Max time.Duration `json:"max"`
+	Sum time.Duration `json:"sum"`
+}
+
+// Rollup summarises the results of a job over Resolution from Start.
+type Rollup struct {
+	Target     string        `json:"target"`
+	Method     string        `json:"method,omitempty"`
+	Start      time.Time     `json:"start"`
+	Resolution time.Duration `json:"resolution"`
+	Count      int           `json:"count"`
+	Passed     int           `json:"passed"`
+	Reachable  int           `json:"reachable"`
+	Latency    Latency       `json:"latency"`
+}
+
+// Key is the one of the job of the results, as monitor.Key makes it. Rollups
+// written before they had a method are the ones of GET jobs.
+func (r Rollup) Key() string {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return method + " " + r.Target
+}
+
+func (r Rollup) Uptime() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+	return float64(r.Passed) / float64(r.Count)
+}
+
+func (r Rollup) Mean() time.Duration {
+	if r.Count == 0 {
+		return 0
+	}
+	return r.Latency.Sum / time.Duration(r.Count)
+}
+
+func (r *Rollup) add(result monitor.Result) {
+	latency := result.Timings.Total
+	if r.Count == 0 || latency < r.Latency.Min {
+		r.Latency.Min = latency
+	}
+	if latency > r.Latency.Max {
+		r.Latency.Max = latency
+	}
+	r.Latency.Sum += latency
+	r.Count++
+	if result.Passed {
+		r.Passed++
+	}
+	if result.Reachable {
+		r.Reachable++
+	}
+}
+
+func (r *Rollup) merge(other Rollup) {
+	if r.Count == 0 || other.Latency.Min < r.Latency.Min {
+		r.Latency.Min = other.Latency.Min
+	}
+	if other.Latency.Max > r.Latency.Max {
+		r.Latency.Max = other.Latency.Max
+	}
+	r.Latency.Sum += other.Latency.Sum
+	r.Count += other.Count
+	r.Passed += other.Passed
+	r.Reachable += other.Reachable
+}
+
+func newRollup(result monitor.Result, resolution time.Duration) Rollup {
+	rollup := Rollup{
+		Target:     result.Location.String(),
+		Method:     result.Method,
+		Start:      result.Time.UTC().Truncate(resolution),
+		Resolution: resolution,
+	}
+	rollup.add(result)
+	return rollup
+}
+
+// insert merges the rollup into rollups sorted by start. Rollups of the
+// same period are written more than once when results arrive out of order
+// or the store is reopened, they are merged here.
+func insert(rollups []Rollup, rollup Rollup) []Rollup {
+	index := sort.Search(len(rollups), func(i int) bool {
+		return !rollups[i].Start.Before(rollup.Start)
+	})
+	if index < len(rollups) && rollups[index].Start.Equal(rollup.Start) {
+		rollups[index].merge(rollup)
+		return rollups
+	}
+	rollups = append(rollups, Rollup{})
+	copy(rollups[index+1:], rollups[index:])
+	rollups[index] = rollup
+	return rollups
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	day       = 24 * time.Hour
+	dayLayout = "20060102"
+	extension = ".ndjson"
+)
+
+// segments are the append-only files of a tier, one per UTC day of the
+// records they hold, named after that day.
+type segments struct {
+	dir     string
+	writers map[string]*os.File
+}
+
+func (s *segments) name(at time.Time) string {
+	return at.UTC().Format(dayLayout)
+}
+
+func (s *segments) path(name string) string {
+	return filepath.Join(s.dir, name+extension)
+}
+
+// append writes the record, a single JSON line, to the segment of its day
+// and returns where it starts.
+func (s *segments) append(name string, record []byte) (int64, error) {
+	writer, ok := s.writers[name]
+	if !ok {
+		if err := s.repair(name); err != nil {
+			return 0, err
+		}
+		file, err := os.OpenFile(s.path(name), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return 0, err
+		}
+		writer, s.writers[name] = file, file
+	}
+	info, err := writer.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := writer.Write(record); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// list returns the names of the segments, oldest first.
+func (s *segments) list() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+extension))
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), extension)
+		if _, err := time.Parse(dayLayout, name); err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// scan calls visit with every record of the segment and where it starts,
+// leaving out a record left incomplete by a crash.
+func (s *segments) scan(name string, visit func(record []byte, offset int64)) error {
+	file, err := os.Open(s.path(name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = lines(file, visit)
+	return err
+}
+
+// repair cuts off a record left incomplete at the end of the segment by a
+// crash, so the next one appended starts on a line of its own.
+func (s *segments) repair(name string) error {
+	file, err := os.OpenFile(s.path(name), os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	complete, err := lines(file, func([]byte, int64) {})
+	if err != nil {
+		return err
+	}
+	return file.Truncate(complete)
+}
+
+// lines calls visit with every complete line of the reader and returns where
+// the complete lines end.
+func lines(reader io.Reader, visit func(record []byte, offset int64)) (int64, error) {
+	var (
+		buffered = bufio.NewReader(reader)
+		offset   int64
+	)
+	for {
+		line, err := buffered.ReadBytes('\n')
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		if record := bytes.TrimSpace(line); len(record) > 0 {
+			visit(line, offset)
+		}
+		offset += int64(len(line))
+	}
+}
+
+// expire removes the segments holding only records older than cutoff and
+// returns their names.
+func (s *segments) expire(cutoff time.Time) ([]string, error) {
+	names, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	expired := []string{}
+	for _, name := range names {
+		start, _ := time.Parse(dayLayout, name)
+		if start.Add(day).After(cutoff) {
+			continue
+		}
+		if writer, ok := s.writers[name]; ok {
+			writer.Close()
+			delete(s.writers, name)
+		}
+		if err := os.Remove(s.path(name)); err != nil {
+			return expired, err
+		}
+		expired = append(expired, name)
+	}
+	return expired, nil
+}
+
+// release closes the writers of the segments before the given one, results
+// rarely arrive that late and they are reopened when they do.
+func (s *segments) release(before string) {
+	for name, writer := range s.writers {
+		if name < before {
+			writer.Close()
+			delete(s.writers, name)
+		}
+	}
+}
+
+func (s *segments) close() error {
+	var first error
+	for name, writer := range s.writers {
+		if err := writer.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(s.writers, name)
+	}
+	return first
+}
+
+func newSegments(dir string) (*segments, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	segments := segments{dir: dir, writers: map[string]*os.File{}}
+	return &segments, nil
+}
@@ -0,0 +1,527 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+// Tier keeps results for Retention, raw when Resolution is zero and rolled
+// up over Resolution otherwise.
+type Tier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+var DefaultTiers = []Tier{
+	{Retention: 7 * day},
+	{Resolution: 5 * time.Minute, Retention: 90 * day},
+}
+
+type tier struct {
+	Tier
+	segments *segments
+	rollups  map[string][]Rollup
+	open     map[string]*Rollup
+}
+
+// roll counts the result in the open rollup of its target, writing the open
+// one out once a result of a later period arrives. Late results are written
+// as a rollup of their own, merged with the others of their period on read.
+func (t *tier) roll(result monitor.Result) error {
+	var (
+		key     = result.Key()
+		rollup  = newRollup(result, t.Resolution)
+		current = t.open[key]
+	)
+	switch {
+	case current == nil:
+		t.open[key] = &rollup
+	case rollup.Start.Equal(current.Start):
+		current.add(result)
+	case rollup.Start.After(current.Start):
+		t.open[key] = &rollup
+		return t.flush(*current)
+	default:
+		return t.flush(rollup)
+	}
+	return nil
+}
+
+func (t *tier) flush(rollup Rollup) error {
+	record, err := json.Marshal(&rollup)
+	if err != nil {
+		return err
+	}
+	if _, err := t.segments.append(t.segments.name(rollup.Start), append(record, '\n')); err != nil {
+		return err
+	}
+	t.rollups[rollup.Key()] = insert(t.rollups[rollup.Key()], rollup)
+	return nil
+}
+
+func (t *tier) expire(cutoff time.Time) error {
+	expired, err := t.segments.expire(cutoff)
+	if len(expired) > 0 {
+		last, _ := time.Parse(dayLayout, expired[len(expired)-1])
+		for key, rollups := range t.rollups {
+			index := sort.Search(len(rollups), func(i int) bool {
+				return !rollups[i].Start.Before(last.Add(day))
+			})
+			t.rollups[key] = rollups[index:]
+		}
+	}
+	return err
+}
+
+// Store keeps the results of every job, keyed as monitor.Key does, in
+// append-only segments, raw and rolled up in tiers of decreasing resolution
+// and increasing retention. The raw results are read from their segments by
+// each query, only the latest one of every job and the rollups are kept in
+// memory.
+type Store struct {
+	lock    *sync.Mutex
+	stamper func() time.Time
+
+	raw       *segments
+	retention time.Duration
+	tiers     []*tier
+	current   string
+
+	// latest is filled from the raw segments the first time a job missing
+	// from it is asked for, the results written since are already in.
+	latest     map[string]monitor.Result
+	backfilled bool
+}
+
+func (s *Store) Write(result monitor.Result) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	record, err := json.Marshal(&result)
+	if err != nil {
+		err := fmt.Errorf("store error: %w", err)
+		return err
+	}
+	record = append(record, '\n')
+	name := s.raw.name(result.Time)
+	if _, err := s.raw.append(name, record); err != nil {
+		err := fmt.Errorf("store error: %w", err)
+		return err
+	}
+	s.keep(result)
+
+	for _, tier := range s.tiers {
+		if err := tier.roll(result); err != nil {
+			err := fmt.Errorf("store error: %w", err)
+			return err
+		}
+	}
+
+	if name > s.current {
+		s.current = name
+		return s.expire()
+	}
+	return nil
+}
+
+// expire removes the segments past the retention of their tier and closes
+// the raw segments older than the day before the current one.
+func (s *Store) expire() error {
+	now := s.stamper()
+	expired, err := s.raw.expire(now.Add(-s.retention))
+	if len(expired) > 0 {
+		removed := map[string]bool{}
+		for _, name := range expired {
+			removed[name] = true
+		}
+		for key, result := range s.latest {
+			if removed[s.raw.name(result.Time)] {
+				delete(s.latest, key)
+			}
+		}
+	}
+	if err != nil {
+		err := fmt.Errorf("store error: %w", err)
+		return err
+	}
+	if current, err := time.Parse(dayLayout, s.current); err == nil {
+		s.raw.release(s.raw.name(current.Add(-day)))
+		for _, tier := range s.tiers {
+			tier.segments.release(tier.segments.name(current.Add(-day)))
+		}
+	}
+	for _, tier := range s.tiers {
+		if err := tier.expire(now.Add(-tier.Retention)); err != nil {
+			err := fmt.Errorf("store error: %w", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Range returns the raw results of the job from from included to to
+// excluded, oldest first, reading the segments of the days in between.
+func (s *Store) Range(key string, from, to time.Time) ([]monitor.Result, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names, err := s.raw.list()
+	if err != nil {
+		err := fmt.Errorf("store error: %w", err)
+		return nil, err
+	}
+	first, last := s.raw.name(from), s.raw.name(to)
+	results := []monitor.Result{}
+	for _, name := range names {
+		if name < first || name > last {
+			continue
+		}
+		err := s.each(name, func(result monitor.Result) {
+			if result.Key() == key && !result.Time.Before(from) && result.Time.Before(to) {
+				results = append(results, result)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Time.Before(results[j].Time)
+	})
+	return results, nil
+}
+
+// Latest returns the most recent raw result of the job, if any.
+func (s *Store) Latest(key string) (monitor.Result, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.latest[key]; !ok {
+		if err := s.backfill(); err != nil {
+			return monitor.Result{}, false, err
+		}
+	}
+	result, ok := s.latest[key]
+	return result, ok, nil
+}
+
+// Rollups returns the rollups of the job at the resolution of one of the
+// tiers, from the one including from to the one before to, oldest first:
+// the periods at both ends are returned whole even when from or to falls
+// within them. The rollup of the current period is included as it stands.
+func (s *Store) Rollups(key string, resolution time.Duration, from, to time.Time) ([]Rollup, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, tier := range s.tiers {
+		if tier.Resolution != resolution {
+			continue
+		}
+		rollups := append([]Rollup{}, tier.rollups[key]...)
+		if current := tier.open[key]; current != nil {
+			rollups = insert(rollups, *current)
+		}
+		from = from.UTC().Truncate(resolution)
+		selected := []Rollup{}
+		for _, rollup := range rollups {
+			if !rollup.Start.Before(from) && rollup.Start.Before(to) {
+				selected = append(selected, rollup)
+			}
+		}
+		return selected, nil
+	}
+	err := fmt.Errorf("store error: no tier with a resolution of %s", resolution)
+	return nil, err
+}
+
+// Uptime returns the share of the results of the job that passed from
+// from included to to excluded, and whether there were any. The periods
+// entirely within the window are counted from the finest rollups, the
+// partial ones at its ends from the raw results.
+func (s *Store) Uptime(key string, from, to time.Time) (float64, bool, error) {
+	var (
+		total = Rollup{}
+		tiers = s.Tiers()
+	)
+	count := func(from, to time.Time) error {
+		results, err := s.Range(key, from, to)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			total.add(result)
+		}
+		return nil
+	}
+	if len(tiers) == 1 {
+		if err := count(from, to); err != nil {
+			return 0, false, err
+		}
+		return total.Uptime(), total.Count > 0, nil
+	}
+
+	resolution := tiers[1].Resolution
+	start, end := s.edges(from, to, resolution)
+	if !start.Before(end) {
+		if err := count(from, to); err != nil {
+			return 0, false, err
+		}
+		return total.Uptime(), total.Count > 0, nil
+	}
+	if err := count(from, start); err != nil {
+		return 0, false, err
+	}
+	rollups, err := s.Rollups(key, resolution, start, end)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, rollup := range rollups {
+		total.merge(rollup)
+	}
+	if err := count(end, to); err != nil {
+		return 0, false, err
+	}
+	return total.Uptime(), total.Count > 0, nil
+}
+
+// edges returns the first and last period boundaries within from and to,
+// between which whole rollups are counted. An end past the raw retention,
+// whose raw results may be gone, keeps its whole period instead.
+func (s *Store) edges(from, to time.Time, resolution time.Duration) (time.Time, time.Time) {
+	cutoff := s.stamper().Add(-s.retention)
+	start, end := from.UTC().Truncate(resolution), to.UTC().Truncate(resolution)
+	if start.Before(from) && !from.Before(cutoff) {
+		start = start.Add(resolution)
+	}
+	if end.Before(to) && to.Before(cutoff) {
+		end = end.Add(resolution)
+	}
+	return start, end
+}
+
+// Keys returns the keys of the jobs with results in any tier, sorted.
+func (s *Store) Keys() ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.backfill(); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for key := range s.latest {
+		seen[key] = true
+	}
+	for _, tier := range s.tiers {
+		for key, rollups := range tier.rollups {
+			if len(rollups) > 0 {
+				seen[key] = true
+			}
+		}
+		for key := range tier.open {
+			seen[key] = true
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Tiers returns the tiers of the store, the raw one first then the rollups
+// from the finest.
+func (s *Store) Tiers() []Tier {
+	tiers := []Tier{{Retention: s.retention}}
+	for _, tier := range s.tiers {
+		tiers = append(tiers, tier.Tier)
+	}
+	return tiers
+}
+
+// Close writes out the rollups of the current periods and closes the
+// segments.
+func (s *Store) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var first error
+	for _, tier := range s.tiers {
+		for key, current := range tier.open {
+			if err := tier.flush(*current); err != nil && first == nil {
+				first = err
+			}
+			delete(tier.open, key)
+		}
+		if err := tier.segments.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	if err := s.raw.close(); err != nil && first == nil {
+		first = err
+	}
+	if first != nil {
+		err := fmt.Errorf("store error: %w", first)
+		return err
+	}
+	return nil
+}
+
+// keep makes the result the latest of its job unless a later one is known.
+func (s *Store) keep(result monitor.Result) {
+	key := result.Key()
+	if latest, ok := s.latest[key]; !ok || !result.Time.Before(latest.Time) {
+		s.latest[key] = result
+	}
+}
+
+// backfill reads the raw segments once for the latest results of the jobs
+// not written since the store was opened.
+func (s *Store) backfill() error {
+	if s.backfilled {
+		return nil
+	}
+	names, err := s.raw.list()
+	if err != nil {
+		err := fmt.Errorf("store error: %w", err)
+		return err
+	}
+	for _, name := range names {
+		if err := s.each(name, s.keep); err != nil {
+			return err
+		}
+	}
+	s.backfilled = true
+	return nil
+}
+
+// each calls visit with every raw result of the segment.
+func (s *Store) each(name string, visit func(monitor.Result)) error {
+	err := s.raw.scan(name, func(record []byte, offset int64) {
+		result := monitor.Result{}
+		if err := json.Unmarshal(record, &result); err != nil || result.Location == nil {
+			return
+		}
+		visit(result)
+	})
+	if err != nil {
+		err := fmt.Errorf("store error: %w", err)
+		return err
+	}
+	return nil
+}
+
+// load reads the rollups of the tiers, the raw results are left in their
+// segments.
+func (s *Store) load() error {
+	names, err := s.raw.list()
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		s.current = names[len(names)-1]
+	}
+
+	for _, tier := range s.tiers {
+		names, err := tier.segments.list()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			visit := func(record []byte, offset int64) {
+				rollup := Rollup{}
+				if err := json.Unmarshal(record, &rollup); err != nil || rollup.Target == "" {
+					return
+				}
+				tier.rollups[rollup.Key()] = insert(tier.rollups[rollup.Key()], rollup)
+			}
+			if err := tier.segments.scan(name, visit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validate(tiers []Tier) error {
+	raw, resolutions := 0, map[time.Duration]bool{}
+	for _, tier := range tiers {
+		switch {
+		case tier.Resolution < 0:
+			return fmt.Errorf("negative resolution %s", tier.Resolution)
+		case tier.Retention <= 0:
+			return fmt.Errorf("retention %s is not positive", tier.Retention)
+		case resolutions[tier.Resolution]:
+			return fmt.Errorf("more than one tier with a resolution of %s", tier.Resolution)
+		case tier.Resolution == 0:
+			raw++
+		}
+		resolutions[tier.Resolution] = true
+	}
+	if raw == 0 {
+		return errors.New("missing raw tier")
+	}
+	return nil
+}
+
+// New opens the store in dir, creating it if needed, with the default tiers
+// when tiers is empty.
+func New(dir string, tiers []Tier, stamper func() time.Time) (*Store, error) {
+	if len(tiers) == 0 {
+		tiers = DefaultTiers
+	}
+	if err := validate(tiers); err != nil {
+		err := fmt.Errorf("store error: %w", err)
+		return nil, err
+	}
+	raw, err := newSegments(filepath.Join(dir, "raw"))
+	if err != nil {
+		err := fmt.Errorf("store error: %w", err)
+		return nil, err
+	}
+	store := Store{
+		lock:    new(sync.Mutex),
+		stamper: stamper,
+		raw:     raw,
+		latest:  map[string]monitor.Result{},
+	}
+
+	sorted := append([]Tier{}, tiers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Resolution < sorted[j].Resolution
+	})
+	for _, configured := range sorted {
+		if configured.Resolution == 0 {
+			store.retention = configured.Retention
+			continue
+		}
+		segments, err := newSegments(filepath.Join(dir, configured.Resolution.String()))
+		if err != nil {
+			err := fmt.Errorf("store error: %w", err)
+			return nil, err
+		}
+		rolled := tier{
+			Tier:     configured,
+			segments: segments,
+			rollups:  map[string][]Rollup{},
+			open:     map[string]*Rollup{},
+		}
+		store.tiers = append(store.tiers, &rolled)
+	}
+
+	if err := store.load(); err != nil {
+		err := fmt.Errorf("store error: %w", err)
+		return nil, err
+	}
+	if err := store.expire(); err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
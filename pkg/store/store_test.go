@@ -0,0 +1,324 @@
+package store_test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/store"
+)
+
+var base = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func result(t *testing.T, target string, at time.Time, passed bool, latency time.Duration) monitor.Result {
+	location, err := url.Parse(target)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	return monitor.Result{
+		Location:  location,
+		Status:    200,
+		Reachable: true,
+		Passed:    passed,
+		Time:      at,
+		Timings:   monitor.Timings{Total: latency},
+	}
+}
+
+// key is the one of the GET job of the target.
+func key(target string) string {
+	return "GET " + target
+}
+
+func open(t *testing.T, dir string, now *time.Time) *store.Store {
+	sut, err := store.New(dir, nil, func() time.Time { return *now })
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	return sut
+}
+
+func write(t *testing.T, sut *store.Store, results ...monitor.Result) {
+	for _, result := range results {
+		if err := sut.Write(result); err != nil {
+			msg := "unwanted error %v"
+			t.Fatalf(msg, err)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	now := base
+	sut := open(t, t.TempDir(), &now)
+	defer sut.Close()
+
+	var (
+		first  = result(t, "https://a.domain.com", base, true, time.Millisecond)
+		second = result(t, "https://a.domain.com", base.Add(time.Minute), false, time.Millisecond)
+		third  = result(t, "https://a.domain.com", base.Add(2*time.Minute), true, time.Millisecond)
+		other  = result(t, "https://b.domain.com", base, true, time.Millisecond)
+	)
+	// Results of concurrent workers may arrive out of order.
+	write(t, sut, first, third, other, second)
+
+	got, err := sut.Range(key("https://a.domain.com"), base.Add(time.Minute), base.Add(time.Hour))
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if want := []monitor.Result{second, third}; !reflect.DeepEqual(want, got) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, want, got)
+	}
+
+	latest, ok, err := sut.Latest(key("https://a.domain.com"))
+	if err != nil || !ok || !reflect.DeepEqual(third, latest) {
+		msg := "want latest %v, got %v, %t and %v"
+		t.Fatalf(msg, third, latest, ok, err)
+	}
+	if _, ok, _ := sut.Latest(key("https://c.domain.com")); ok {
+		t.Fatal("want no latest result for an unknown target")
+	}
+
+	want := []string{"GET https://a.domain.com", "GET https://b.domain.com"}
+	if got, err := sut.Keys(); err != nil || !reflect.DeepEqual(want, got) {
+		msg := "want keys %v, got %v and %v"
+		t.Fatalf(msg, want, got, err)
+	}
+}
+
+func TestRollups(t *testing.T) {
+	now := base
+	sut := open(t, t.TempDir(), &now)
+	defer sut.Close()
+
+	target := "https://a.domain.com"
+	write(t, sut,
+		result(t, target, base, true, 10*time.Millisecond),
+		result(t, target, base.Add(time.Minute), false, 30*time.Millisecond),
+		result(t, target, base.Add(5*time.Minute), true, 20*time.Millisecond),
+		result(t, target, base.Add(2*time.Minute), true, 50*time.Millisecond),
+	)
+
+	got, err := sut.Rollups(key(target), 5*time.Minute, base.Add(time.Minute), base.Add(time.Hour))
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	want := []store.Rollup{
+		{
+			Target: target, Start: base, Resolution: 5 * time.Minute,
+			Count: 3, Passed: 2, Reachable: 3,
+			Latency: store.Latency{Min: 10 * time.Millisecond, Max: 50 * time.Millisecond, Sum: 90 * time.Millisecond},
+		},
+		{
+			Target: target, Start: base.Add(5 * time.Minute), Resolution: 5 * time.Minute,
+			Count: 1, Passed: 1, Reachable: 1,
+			Latency: store.Latency{Min: 20 * time.Millisecond, Max: 20 * time.Millisecond, Sum: 20 * time.Millisecond},
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		msg := "want %+v, got %+v"
+		t.Fatalf(msg, want, got)
+	}
+	if mean := got[0].Mean(); mean != 30*time.Millisecond {
+		msg := "want a mean latency of 30ms, got %s"
+		t.Fatalf(msg, mean)
+	}
+
+	uptime, ok, err := sut.Uptime(key(target), base, base.Add(time.Hour))
+	if err != nil || !ok || uptime != 0.75 {
+		msg := "want an uptime of 0.75, got %v, %t and %v"
+		t.Fatalf(msg, uptime, ok, err)
+	}
+	if _, err := sut.Rollups(key(target), time.Hour, base, base.Add(time.Hour)); err == nil {
+		t.Fatal("want an error for a resolution without tier, got nothing")
+	}
+}
+
+func TestUptimeEdges(t *testing.T) {
+	now := base.Add(15 * time.Minute)
+	sut := open(t, t.TempDir(), &now)
+	defer sut.Close()
+
+	// Only the results outside of the window, in its first and last 5
+	// minutes periods, fail.
+	target := "https://a.domain.com"
+	for i := 0; i < 15; i++ {
+		at := base.Add(time.Duration(i) * time.Minute)
+		passed := i >= 3 && i < 13
+		write(t, sut, result(t, target, at, passed, time.Millisecond))
+	}
+	from, to := base.Add(3*time.Minute), base.Add(13*time.Minute)
+
+	uptime, ok, err := sut.Uptime(key(target), from, to)
+	if err != nil || !ok || uptime != 1 {
+		msg := "want an uptime of 1, got %v, %t and %v"
+		t.Fatalf(msg, uptime, ok, err)
+	}
+
+	// Past the raw retention the periods at both ends are counted whole.
+	now = base.Add(8 * 24 * time.Hour)
+	uptime, ok, err = sut.Uptime(key(target), from, to)
+	if want := 10.0 / 15; err != nil || !ok || uptime != want {
+		msg := "want an uptime of %v, got %v, %t and %v"
+		t.Fatalf(msg, want, uptime, ok, err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	now := base
+	dir := t.TempDir()
+	target := "https://a.domain.com"
+	first := result(t, target, base, true, time.Millisecond)
+
+	sut := open(t, dir, &now)
+	write(t, sut, first)
+	if err := sut.Close(); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+
+	// A crash may leave a record incomplete at the end of a segment.
+	segment := filepath.Join(dir, "raw", "20240501.ndjson")
+	file, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	file.WriteString(`{"Location":`)
+	file.Close()
+
+	second := result(t, target, base.Add(time.Minute), false, time.Millisecond)
+	sut = open(t, dir, &now)
+	defer sut.Close()
+	write(t, sut, second)
+
+	got, err := sut.Range(key(target), base, base.Add(time.Hour))
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if want := []monitor.Result{first, second}; !reflect.DeepEqual(want, got) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, want, got)
+	}
+
+	rollups, err := sut.Rollups(key(target), 5*time.Minute, base, base.Add(time.Hour))
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if len(rollups) != 1 || rollups[0].Count != 2 || rollups[0].Passed != 1 {
+		msg := "want one rollup of both results, got %+v"
+		t.Fatalf(msg, rollups)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	now := base
+	dir := t.TempDir()
+	target := "https://a.domain.com"
+
+	// The GET and HEAD jobs of the same location are kept apart, in the raw
+	// results as in the rollups, before and after the store is reopened.
+	get := result(t, target, base, true, time.Millisecond)
+	head := result(t, target, base.Add(time.Minute), false, time.Millisecond)
+	head.Method = "HEAD"
+	sut := open(t, dir, &now)
+	write(t, sut, get, head)
+	if err := sut.Close(); err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	sut = open(t, dir, &now)
+	defer sut.Close()
+
+	want := []string{"GET https://a.domain.com", "HEAD https://a.domain.com"}
+	if got, err := sut.Keys(); err != nil || !reflect.DeepEqual(want, got) {
+		msg := "want keys %v, got %v and %v"
+		t.Fatalf(msg, want, got, err)
+	}
+	for key, want := range map[string]monitor.Result{want[0]: get, want[1]: head} {
+		got, err := sut.Range(key, base, base.Add(time.Hour))
+		if err != nil || !reflect.DeepEqual([]monitor.Result{want}, got) {
+			msg := "%s: want %v, got %v and %v"
+			t.Fatalf(msg, key, want, got, err)
+		}
+		latest, ok, err := sut.Latest(key)
+		if err != nil || !ok || !reflect.DeepEqual(want, latest) {
+			msg := "%s: want latest %v, got %v, %t and %v"
+			t.Fatalf(msg, key, want, latest, ok, err)
+		}
+		rollups, err := sut.Rollups(key, 5*time.Minute, base, base.Add(time.Hour))
+		if err != nil || len(rollups) != 1 || rollups[0].Count != 1 {
+			msg := "%s: want a rollup of one result, got %+v and %v"
+			t.Fatalf(msg, key, rollups, err)
+		}
+	}
+	if uptime, ok, err := sut.Uptime("HEAD "+target, base, base.Add(time.Hour)); err != nil || !ok || uptime != 0 {
+		msg := "want an uptime of 0 for HEAD, got %v, %t and %v"
+		t.Fatalf(msg, uptime, ok, err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	now := base
+	dir := t.TempDir()
+	target := "https://a.domain.com"
+	old := result(t, target, base.Add(-10*24*time.Hour), true, time.Millisecond)
+	recent := result(t, target, base, true, time.Millisecond)
+
+	sut := open(t, dir, &now)
+	defer sut.Close()
+	write(t, sut, old, result(t, target, old.Time.Add(5*time.Minute), true, time.Millisecond), recent)
+
+	// The raw results are kept for a week, their rollups for 90 days.
+	got, err := sut.Range(key(target), old.Time, base.Add(time.Hour))
+	if err != nil {
+		msg := "unwanted error %v"
+		t.Fatalf(msg, err)
+	}
+	if want := []monitor.Result{recent}; !reflect.DeepEqual(want, got) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, want, got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "raw", "20240421.ndjson")); !os.IsNotExist(err) {
+		msg := "want the expired segment removed, got %v"
+		t.Fatalf(msg, err)
+	}
+	rollups, err := sut.Rollups(key(target), 5*time.Minute, old.Time, base)
+	if err != nil || len(rollups) != 2 || rollups[0].Start != old.Time {
+		msg := "want the rollups of the expired results, got %+v and %v"
+		t.Fatalf(msg, rollups, err)
+	}
+
+	now = base.Add(91 * 24 * time.Hour)
+	write(t, sut, result(t, target, now, true, time.Millisecond))
+	rollups, err = sut.Rollups(key(target), 5*time.Minute, old.Time, now)
+	if err != nil || len(rollups) != 0 {
+		msg := "want the expired rollups removed, got %+v and %v"
+		t.Fatalf(msg, rollups, err)
+	}
+}
+
+func TestNewError(t *testing.T) {
+	tests := map[string][]store.Tier{
+		"missing raw tier":     {{Resolution: time.Minute, Retention: time.Hour}},
+		"duplicate resolution": {{Retention: time.Hour}, {Retention: 2 * time.Hour}},
+		"negative resolution":  {{Retention: time.Hour}, {Resolution: -time.Minute, Retention: time.Hour}},
+		"missing retention":    {{Retention: time.Hour}, {Resolution: time.Minute}},
+	}
+	for name, tiers := range tests {
+		if _, err := store.New(t.TempDir(), tiers, time.Now); err == nil {
+			msg := "%s: want an error, got nothing"
+			t.Fatalf(msg, name)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package ticker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes  = field{name: "minute", min: 0, max: 59}
+	hours    = field{name: "hour", min: 0, max: 23}
+	days     = field{name: "day of month", min: 1, max: 31}
+	months   = field{name: "month", min: 1, max: 12, names: map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}}
+	weekdays = field{name: "day of week", min: 0, max: 7, names: map[string]int{"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6}}
+)
+
+type Cron struct {
+	spec     string
+	location *time.Location
+
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool
+}
+
+// ParseCron parses the spec of a schedule run in location, UTC when nil as
+// for definitions without a timezone.
+func ParseCron(spec string, location *time.Location) (Cron, error) {
+	if location == nil {
+		location = time.UTC
+	}
+	expanded := spec
+	if macro, ok := macros[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		expanded = macro
+	}
+
+	fields := strings.Fields(expanded)
+	if len(fields) != 5 {
+		err := fmt.Errorf("cron %q: want 5 fields, got %d", spec, len(fields))
+		return Cron{}, err
+	}
+
+	cron := Cron{spec: spec, location: location}
+	parsed := []*uint64{&cron.minutes, &cron.hours, &cron.days, &cron.months, &cron.weekdays}
+	for i, f := range []field{minutes, hours, days, months, weekdays} {
+		bits, err := f.parse(fields[i])
+		if err != nil {
+			err := fmt.Errorf("cron %q: %w", spec, err)
+			return Cron{}, err
+		}
+		*parsed[i] = bits
+	}
+	if cron.weekdays&(1<<7) != 0 {
+		cron.weekdays |= 1
+	}
+	cron.anyDay, cron.anyWeekday = fields[2] == "*", fields[4] == "*"
+	return cron, nil
+}
+
+func (c Cron) String() string {
+	return c.spec
+}
+
+func (c Cron) Location() *time.Location {
+	return c.location
+}
+
+// Next walks wall clock times, kept in UTC so the walk never meets a clock
+// change, and places each match in the location. The walk starts a few hours
+// before after so wall times repeated when clocks go back are found again:
+// they run at each of their instants. Wall times skipped when clocks go
+// forward run shifted by the gap.
+func (c Cron) Next(after time.Time) time.Time {
+	local := after.In(c.location)
+	t := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), 0, 0, time.UTC)
+	t = t.Add(-lookback)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case c.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.day(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+		case c.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			if next, ok := c.place(t, after); ok {
+				return next
+			}
+			t = t.Add(time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+// lookback covers the largest clock change of any timezone.
+const lookback = 3 * time.Hour
+
+// place returns the first instant after after showing the wall clock time in
+// the location, if any.
+func (c Cron) place(wall, after time.Time) (time.Time, bool) {
+	shifted := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, c.location)
+	instants := []time.Time{}
+	for _, around := range []time.Time{shifted.Add(-12 * time.Hour), shifted.Add(12 * time.Hour)} {
+		_, offset := around.Zone()
+		instant := wall.Add(-time.Duration(offset) * time.Second).In(c.location)
+		if instant.Hour() == wall.Hour() && instant.Minute() == wall.Minute() && instant.Day() == wall.Day() {
+			instants = append(instants, instant)
+		}
+	}
+	if len(instants) == 0 {
+		instants = append(instants, shifted)
+	}
+	for _, instant := range instants {
+		if instant.After(after) {
+			return instant, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// day follows cron semantics: when both day of month and day of week are
+// restricted, either of them matching is enough.
+func (c Cron) day(t time.Time) bool {
+	var (
+		day     = c.days&(1<<uint(t.Day())) != 0
+		weekday = c.weekdays&(1<<uint(t.Weekday())) != 0
+	)
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func (f field) parse(expression string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expression, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				err := fmt.Errorf("invalid %s step %q", f.name, part[i+1:])
+				return 0, err
+			}
+			part, step = part[:i], n
+		}
+
+		min, max := f.min, f.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			low, err := f.value(bounds[0])
+			if err != nil {
+				return 0, err
+			}
+			high, err := f.value(bounds[1])
+			if err != nil {
+				return 0, err
+			}
+			if low > high {
+				err := fmt.Errorf("invalid %s range %q", f.name, part)
+				return 0, err
+			}
+			min, max = low, high
+		default:
+			value, err := f.value(part)
+			if err != nil {
+				return 0, err
+			}
+			min = value
+			if step == 1 {
+				max = value
+			}
+		}
+
+		for value := min; value <= max; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(token string) (int, error) {
+	if value, ok := f.names[strings.ToUpper(token)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(token)
+	if err != nil || value < f.min || value > f.max {
+		err := fmt.Errorf("invalid %s %q", f.name, token)
+		return 0, err
+	}
+	return value, nil
+}
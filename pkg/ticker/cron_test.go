@@ -0,0 +1,231 @@
+package ticker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/ticker"
+)
+
+func TestCronNext(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	tests := map[string]struct {
+		spec     string
+		location *time.Location
+		after    time.Time
+		want     time.Time
+	}{
+		"every minute": {
+			spec:  "* * * * *",
+			after: time.Date(2024, 3, 1, 10, 30, 15, 0, time.UTC),
+			want:  time.Date(2024, 3, 1, 10, 31, 0, 0, time.UTC),
+		},
+		"every five minutes": {
+			spec:  "*/5 * * * *",
+			after: time.Date(2024, 3, 1, 10, 31, 0, 0, time.UTC),
+			want:  time.Date(2024, 3, 1, 10, 35, 0, 0, time.UTC),
+		},
+		"business hours during the day": {
+			spec:  "*/5 9-18 * * MON-FRI",
+			after: time.Date(2024, 3, 1, 12, 2, 0, 0, time.UTC),
+			want:  time.Date(2024, 3, 1, 12, 5, 0, 0, time.UTC),
+		},
+		"business hours after friday evening": {
+			spec:  "*/5 9-18 * * MON-FRI",
+			after: time.Date(2024, 3, 1, 18, 55, 0, 0, time.UTC),
+			want:  time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC),
+		},
+		"business hours in a timezone": {
+			spec:     "0 9 * * mon-fri",
+			location: paris,
+			after:    time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC),
+			want:     time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC),
+		},
+		"in the gap when clocks go forward": {
+			spec:     "30 2 * * *",
+			location: paris,
+			after:    time.Date(2024, 3, 30, 3, 0, 0, 0, paris),
+			want:     time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC),
+		},
+		"right before clocks go forward": {
+			spec:     "*/30 * * * *",
+			location: paris,
+			after:    time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC),
+			want:     time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC),
+		},
+		"first of a repeated time when clocks go back": {
+			spec:     "30 2 * * *",
+			location: paris,
+			after:    time.Date(2024, 10, 27, 0, 0, 0, 0, time.UTC),
+			want:     time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC),
+		},
+		"second of a repeated time when clocks go back": {
+			spec:     "30 2 * * *",
+			location: paris,
+			after:    time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC),
+			want:     time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC),
+		},
+		"after a repeated time when clocks go back": {
+			spec:     "30 2 * * *",
+			location: paris,
+			after:    time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC),
+			want:     time.Date(2024, 10, 28, 1, 30, 0, 0, time.UTC),
+		},
+		"through the repeated hour when clocks go back": {
+			spec:     "*/15 * * * *",
+			location: paris,
+			after:    time.Date(2024, 10, 27, 0, 45, 0, 0, time.UTC),
+			want:     time.Date(2024, 10, 27, 1, 0, 0, 0, time.UTC),
+		},
+		"list and names": {
+			spec:  "0 0 1,15 jan,jul *",
+			after: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"day of month or day of week": {
+			spec:  "0 0 13 * 5",
+			after: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2024, 9, 6, 0, 0, 0, 0, time.UTC),
+		},
+		"sunday as seven": {
+			spec:  "0 12 * * 7",
+			after: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC),
+		},
+		"leap day": {
+			spec:  "0 0 29 2 *",
+			after: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		"macro": {
+			spec:  "@hourly",
+			after: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			want:  time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+		},
+		"never": {
+			spec:  "0 0 30 2 *",
+			after: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Time{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			location := test.location
+			if location == nil {
+				location = time.UTC
+			}
+			cron, err := ticker.ParseCron(test.spec, location)
+			if err != nil {
+				msg := "unwanted error: %v"
+				t.Fatalf(msg, err)
+			}
+			if got := cron.Next(test.after); !got.Equal(test.want) {
+				msg := "want %v, got %v"
+				t.Fatalf(msg, test.want, got)
+			}
+		})
+	}
+}
+
+func TestCronNextTransitions(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	days := []time.Time{
+		time.Date(2024, 3, 30, 22, 0, 0, 0, time.UTC),
+		time.Date(2024, 10, 26, 22, 0, 0, 0, time.UTC),
+	}
+	for _, spec := range []string{"* * * * *", "*/15 * * * *", "30 2 * * *", "0 * * * *"} {
+		cron, err := ticker.ParseCron(spec, paris)
+		if err != nil {
+			msg := "unwanted error: %v"
+			t.Fatalf(msg, err)
+		}
+		for _, day := range days {
+			for after := day; after.Before(day.Add(6 * time.Hour)); after = after.Add(time.Minute) {
+				if next := cron.Next(after); !next.After(after) {
+					msg := "%s: want a time after %v, got %v"
+					t.Fatalf(msg, spec, after, next)
+				}
+			}
+		}
+	}
+}
+
+func TestParseCronUTC(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	local := time.Local
+	time.Local = paris
+	defer func() { time.Local = local }()
+
+	cron, err := ticker.ParseCron("0 12 * * *", nil)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	after := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	want := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if got := cron.Next(after); !got.Equal(want) {
+		msg := "want %v, got %v"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestParseCronError(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * * FUN",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"a-b * * * *",
+	}
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			if _, err := ticker.ParseCron(spec, time.UTC); err == nil {
+				t.Fatal("want an error, got nothing")
+			}
+		})
+	}
+}
+
+func TestTickCron(t *testing.T) {
+	cron, err := ticker.ParseCron("* * * * *", time.UTC)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	options := ticker.Options{Immediate: true, Spread: true}
+	ticker := ticker.New(entries(jobs(t, 3), cron), options)
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	go ticker.Tick(ctx)
+
+	for job := range ticker.Jobsc() {
+		now := time.Now()
+		if now.Second() != 0 {
+			msg := "want cron jobs to wait for their schedule, got %v at %v"
+			t.Fatalf(msg, job.Location, now)
+		}
+	}
+}
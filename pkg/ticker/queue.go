@@ -0,0 +1,46 @@
+package ticker
+
+import (
+	"time"
+)
+
+type item struct {
+	key       string
+	entry     Entry
+	order     int
+	index     int
+	base, at  time.Time
+	immediate bool
+}
+
+type queue []*item
+
+func (q queue) Len() int {
+	return len(q)
+}
+
+func (q queue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].order < q[j].order
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *queue) Push(x interface{}) {
+	item := x.(*item)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	last := old[len(old)-1]
+	last.index = -1
+	*q = old[:len(old)-1]
+	return last
+}
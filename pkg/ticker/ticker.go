@@ -1,43 +1,295 @@
 package ticker
 
 import (
+	"container/heap"
 	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ksahli/baal/pkg/monitor"
 )
 
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+type Interval time.Duration
+
+func (i Interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+type Entry struct {
+	Job      monitor.Job
+	Schedule Schedule
+}
+
+type Options struct {
+	Immediate bool
+	Jitter    time.Duration
+	Spread    bool
+}
+
+type Changes struct {
+	Added       int
+	Removed     int
+	Rescheduled int
+	Updated     int
+}
+
+type update struct {
+	entries []Entry
+	reply   chan Changes
+}
+
 type Ticker struct {
-	frequency time.Duration
-	jobs      []monitor.Job
-	jobsc     chan monitor.Job
+	started int64
+
+	entries []Entry
+	options Options
+	random  *rand.Rand
+	jobsc   chan monitor.Job
+
+	updates chan update
+	stop    chan struct{}
+	once    *sync.Once
+	done    chan struct{}
+
+	queue queue
+	items map[string]*item
 }
 
 func (t *Ticker) Tick(ctx context.Context) {
+	if !atomic.CompareAndSwapInt64(&t.started, 0, 1) {
+		return
+	}
 	defer close(t.jobsc)
+	defer close(t.done)
+
+	t.apply(t.entries, time.Now())
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
 
 	for {
+		var wake <-chan time.Time
+		if len(t.queue) > 0 {
+			timer.Reset(time.Until(t.queue[0].at))
+			wake = timer.C
+		}
+
 		select {
-		case <-time.Tick(t.frequency):
-			for _, job := range t.jobs {
-				t.jobsc <- job
+		case <-wake:
+		case request := <-t.updates:
+			if wake != nil && !timer.Stop() {
+				<-timer.C
 			}
+			request.reply <- t.apply(request.entries, time.Now())
+			continue
 		case <-ctx.Done():
 			return
+		case <-t.stop:
+			return
+		}
+
+		for len(t.queue) > 0 && !t.queue[0].at.After(time.Now()) {
+			next := t.queue[0]
+			select {
+			case t.jobsc <- next.entry.Job:
+			case <-ctx.Done():
+				return
+			case <-t.stop:
+				return
+			}
+			if next.immediate {
+				next.immediate = false
+				next.at = t.jitter(next.base)
+				heap.Fix(&t.queue, 0)
+				continue
+			}
+			next.base = advance(next.entry.Schedule, next.base, time.Now())
+			if next.base.IsZero() {
+				heap.Pop(&t.queue)
+				continue
+			}
+			next.at = t.jitter(next.base)
+			heap.Fix(&t.queue, 0)
 		}
 	}
 }
 
+// Update replaces the scheduled entries while the ticker runs. Entries are
+// matched on method and location: unchanged schedules keep their next run,
+// changed ones are scheduled again from now and new ones are started like at
+// startup.
+func (t *Ticker) Update(entries []Entry) Changes {
+	request := update{entries: entries, reply: make(chan Changes, 1)}
+	select {
+	case t.updates <- request:
+	case <-t.stop:
+		return Changes{}
+	case <-t.done:
+		return Changes{}
+	}
+	return <-request.reply
+}
+
+// Stop ends Tick and waits for it to release its timer and close the jobs
+// channel. It can be called more than once, and before Tick: a Tick not yet
+// running when Stop is called never runs, its jobs channel is closed here.
+func (t *Ticker) Stop() {
+	t.once.Do(func() {
+		close(t.stop)
+		if atomic.CompareAndSwapInt64(&t.started, 0, 1) {
+			close(t.jobsc)
+			close(t.done)
+		}
+	})
+	<-t.done
+}
+
 func (t *Ticker) Jobsc() <-chan monitor.Job {
 	return t.jobsc
 }
 
-func New(frequency time.Duration, jobs []monitor.Job) *Ticker {
-	jobsc := make(chan monitor.Job, 100)
+func (t *Ticker) apply(entries []Entry, now time.Time) Changes {
+	var (
+		changes = Changes{}
+		items   = make(map[string]*item, len(entries))
+		seen    = map[string]int{}
+	)
+	for order, entry := range entries {
+		key := entry.Job.Key()
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key = fmt.Sprintf("%s #%d", key, n)
+		}
+
+		current, ok := t.items[key]
+		switch {
+		case !ok:
+			current = t.schedule(key, entry, now, t.options.Immediate)
+			changes.Added++
+		case !same(current.entry.Schedule, entry.Schedule):
+			t.remove(current)
+			current = t.schedule(key, entry, now, false)
+			changes.Rescheduled++
+		case !reflect.DeepEqual(current.entry.Job, entry.Job):
+			current.entry = entry
+			changes.Updated++
+		}
+		current.order = order
+		items[key] = current
+	}
+	for key, current := range t.items {
+		if _, ok := items[key]; !ok {
+			t.remove(current)
+			changes.Removed++
+		}
+	}
+	t.items = items
+	heap.Init(&t.queue)
+	return changes
+}
+
+func (t *Ticker) schedule(key string, entry Entry, now time.Time, immediate bool) *item {
+	scheduled := &item{key: key, entry: entry, index: -1}
+	interval, ok := entry.Schedule.(Interval)
+	if ok && interval <= 0 {
+		return scheduled
+	}
+	scheduled.base = t.first(entry, now)
+	if scheduled.base.IsZero() {
+		return scheduled
+	}
+	scheduled.at = t.jitter(scheduled.base)
+	if ok && immediate {
+		scheduled.at, scheduled.immediate = now, true
+	}
+	heap.Push(&t.queue, scheduled)
+	return scheduled
+}
+
+func (t *Ticker) remove(scheduled *item) {
+	if scheduled.index >= 0 {
+		heap.Remove(&t.queue, scheduled.index)
+	}
+}
+
+func (t *Ticker) first(entry Entry, now time.Time) time.Time {
+	interval, ok := entry.Schedule.(Interval)
+	if !ok || !t.options.Spread {
+		return entry.Schedule.Next(now)
+	}
+	if offset := spread(entry.Job, time.Duration(interval)); offset > 0 {
+		return now.Add(offset)
+	}
+	return entry.Schedule.Next(now)
+}
+
+// advance returns the occurrence after base, skipping the ones already past
+// now so a job late because the process stalled or the pool was full runs
+// once rather than once per missed occurrence. Intervals keep their phase.
+func advance(schedule Schedule, base, now time.Time) time.Time {
+	next := schedule.Next(base)
+	if next.IsZero() || next.After(now) {
+		return next
+	}
+	if interval, ok := schedule.(Interval); ok {
+		missed := now.Sub(next)/time.Duration(interval) + 1
+		return next.Add(missed * time.Duration(interval))
+	}
+	return schedule.Next(now)
+}
+
+func (t *Ticker) jitter(base time.Time) time.Time {
+	if t.options.Jitter <= 0 {
+		return base
+	}
+	return base.Add(time.Duration(t.random.Int63n(int64(t.options.Jitter))))
+}
+
+// spread derives a stable offset within the interval from the job, so jobs
+// sharing an interval are evenly distributed across it between restarts.
+func spread(job monitor.Job, interval time.Duration) time.Duration {
+	hash := fnv.New64a()
+	hash.Write([]byte(job.Method + " " + job.Location.String()))
+	return time.Duration(hash.Sum64() % uint64(interval))
+}
+
+func same(a, b Schedule) bool {
+	x, ok := a.(Cron)
+	y, other := b.(Cron)
+	if ok && other {
+		return x.spec == y.spec && x.location.String() == y.location.String()
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func New(entries []Entry, options Options) *Ticker {
+	var (
+		random  = rand.New(rand.NewSource(time.Now().UnixNano()))
+		jobsc   = make(chan monitor.Job, 100)
+		updates = make(chan update)
+		stop    = make(chan struct{})
+		once    = new(sync.Once)
+		done    = make(chan struct{})
+	)
 	ticker := Ticker{
-		frequency: frequency,
-		jobs:      jobs,
-		jobsc:     jobsc,
+		entries: entries,
+		options: options,
+		random:  random,
+		jobsc:   jobsc,
+		updates: updates,
+		stop:    stop,
+		once:    once,
+		done:    done,
 	}
 	return &ticker
 }
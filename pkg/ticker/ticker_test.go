@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
+	"runtime"
 	"testing"
 	"time"
 
@@ -14,9 +15,9 @@ import (
 
 var ctx = context.Background()
 
-func TestTick(t *testing.T) {
-	want := make([]monitor.Job, 0, 10)
-	for i := 0; i < 10; i++ {
+func jobs(t *testing.T, count int) []monitor.Job {
+	jobs := make([]monitor.Job, 0, count)
+	for i := 0; i < count; i++ {
 		f := fmt.Sprintf("https://domain-%d.com", i)
 		location, err := url.Parse(f)
 		if err != nil {
@@ -27,11 +28,24 @@ func TestTick(t *testing.T) {
 			Location: location,
 			Method:   "GET",
 		}
-		want = append(want, job)
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func entries(jobs []monitor.Job, schedule ticker.Schedule) []ticker.Entry {
+	entries := make([]ticker.Entry, 0, len(jobs))
+	for _, job := range jobs {
+		entries = append(entries, ticker.Entry{Job: job, Schedule: schedule})
 	}
-	ticker := ticker.New(2*time.Second, want)
+	return entries
+}
+
+func TestTick(t *testing.T) {
+	want := jobs(t, 10)
+	ticker := ticker.New(entries(want, ticker.Interval(200*time.Millisecond)), ticker.Options{})
 
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
 	defer cancel()
 	go ticker.Tick(ctx)
 
@@ -45,3 +59,221 @@ func TestTick(t *testing.T) {
 		t.Fatalf(msg, want, got)
 	}
 }
+
+func TestTickImmediate(t *testing.T) {
+	want := jobs(t, 10)
+	options := ticker.Options{Immediate: true}
+	ticker := ticker.New(entries(want, ticker.Interval(time.Hour)), options)
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	go ticker.Tick(ctx)
+
+	got := map[string]int{}
+	for job := range ticker.Jobsc() {
+		got[job.Location.String()]++
+	}
+
+	for _, job := range want {
+		if count := got[job.Location.String()]; count != 1 {
+			msg := "want 1 run of %s, got %d"
+			t.Fatalf(msg, job.Location, count)
+		}
+	}
+}
+
+func TestTickSpread(t *testing.T) {
+	want := jobs(t, 20)
+	interval := 500 * time.Millisecond
+	options := ticker.Options{Spread: true}
+	ticker := ticker.New(entries(want, ticker.Interval(interval)), options)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, interval)
+	defer cancel()
+	go ticker.Tick(ctx)
+
+	arrivals := map[time.Duration]bool{}
+	got := map[string]int{}
+	for job := range ticker.Jobsc() {
+		got[job.Location.String()]++
+		arrivals[time.Since(start).Round(50*time.Millisecond)] = true
+	}
+
+	if len(got) < len(want)*3/4 {
+		msg := "want most jobs to run within the first interval, got %d of %d"
+		t.Fatalf(msg, len(got), len(want))
+	}
+	if len(arrivals) < 3 {
+		msg := "want runs spread across the interval, got %v"
+		t.Fatalf(msg, arrivals)
+	}
+}
+
+func TestTickJitter(t *testing.T) {
+	want := jobs(t, 5)
+	options := ticker.Options{Jitter: 50 * time.Millisecond}
+	ticker := ticker.New(entries(want, ticker.Interval(100*time.Millisecond)), options)
+
+	ctx, cancel := context.WithTimeout(ctx, 1050*time.Millisecond)
+	defer cancel()
+	go ticker.Tick(ctx)
+
+	got := map[string]int{}
+	for job := range ticker.Jobsc() {
+		got[job.Location.String()]++
+	}
+
+	for _, job := range want {
+		count := got[job.Location.String()]
+		if count < 8 || count > 10 {
+			msg := "want 8 to 10 runs of %s, got %d"
+			t.Fatalf(msg, job.Location, count)
+		}
+	}
+}
+
+func TestTickOverdue(t *testing.T) {
+	// More jobs than the jobs channel holds, so the ticker blocks while
+	// nothing reads and every job falls behind its schedule.
+	want := jobs(t, 150)
+	options := ticker.Options{Immediate: true}
+	ticker := ticker.New(entries(want, ticker.Interval(50*time.Millisecond)), options)
+
+	ctx, cancel := context.WithTimeout(ctx, 800*time.Millisecond)
+	defer cancel()
+	go ticker.Tick(ctx)
+	time.Sleep(600 * time.Millisecond)
+
+	got := map[string]int{}
+	for job := range ticker.Jobsc() {
+		got[job.Location.String()]++
+	}
+
+	for _, job := range want {
+		if count := got[job.Location.String()]; count > 8 {
+			msg := "want the missed runs of %s skipped, got %d runs"
+			t.Fatalf(msg, job.Location, count)
+		}
+	}
+}
+
+func TestTickEmpty(t *testing.T) {
+	ticker := ticker.New(nil, ticker.Options{Immediate: true})
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	go ticker.Tick(ctx)
+
+	for job := range ticker.Jobsc() {
+		msg := "want no jobs, got %v"
+		t.Fatalf(msg, job)
+	}
+}
+
+func TestTickUpdate(t *testing.T) {
+	all := jobs(t, 3)
+	scheduler := ticker.New(entries(all[:2], ticker.Interval(time.Hour)), ticker.Options{})
+
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	go scheduler.Tick(ctx)
+
+	updated := []ticker.Entry{
+		{Job: all[1], Schedule: ticker.Interval(time.Hour)},
+		{Job: all[2], Schedule: ticker.Interval(100 * time.Millisecond)},
+	}
+	want := ticker.Changes{Added: 1, Removed: 1}
+	if got := scheduler.Update(updated); got != want {
+		msg := "\nwant %+v\n  got %+v"
+		t.Fatalf(msg, want, got)
+	}
+
+	updated[0].Schedule = ticker.Interval(100 * time.Millisecond)
+	want = ticker.Changes{Rescheduled: 1}
+	if got := scheduler.Update(updated); got != want {
+		msg := "\nwant %+v\n  got %+v"
+		t.Fatalf(msg, want, got)
+	}
+
+	got := map[string]int{}
+	for job := range scheduler.Jobsc() {
+		got[job.Location.String()]++
+	}
+
+	if count := got[all[0].Location.String()]; count != 0 {
+		msg := "want no runs of removed %s, got %d"
+		t.Fatalf(msg, all[0].Location, count)
+	}
+	for _, job := range all[1:] {
+		if count := got[job.Location.String()]; count < 3 {
+			msg := "want at least 3 runs of %s, got %d"
+			t.Fatalf(msg, job.Location, count)
+		}
+	}
+
+	if got := scheduler.Update(nil); got != (ticker.Changes{}) {
+		msg := "want no changes once stopped, got %+v"
+		t.Fatalf(msg, got)
+	}
+}
+
+func TestStop(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	want := jobs(t, 10)
+	scheduler := ticker.New(entries(want, ticker.Interval(10*time.Millisecond)), ticker.Options{Immediate: true})
+	go scheduler.Tick(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	scheduler.Stop()
+	scheduler.Stop()
+
+	for range scheduler.Jobsc() {
+	}
+	if got := scheduler.Update(entries(want, ticker.Interval(time.Hour))); got != (ticker.Changes{}) {
+		msg := "want no changes once stopped, got %+v"
+		t.Fatalf(msg, got)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			msg := "want %d goroutines once stopped, got %d"
+			t.Fatalf(msg, baseline, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStopRightAfterTick(t *testing.T) {
+	scheduler := ticker.New(entries(jobs(t, 1), ticker.Interval(time.Hour)), ticker.Options{})
+	go scheduler.Tick(ctx)
+	scheduler.Stop()
+
+	select {
+	case _, ok := <-scheduler.Jobsc():
+		if ok {
+			t.Fatal("want no job once stopped, got one")
+		}
+	default:
+		t.Fatal("want the jobs channel closed once Stop returns, still open")
+	}
+}
+
+func TestStopBeforeTick(t *testing.T) {
+	scheduler := ticker.New(entries(jobs(t, 1), ticker.Interval(10*time.Millisecond)), ticker.Options{Immediate: true})
+	scheduler.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.Tick(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("want Tick to return once stopped, still running")
+	}
+}
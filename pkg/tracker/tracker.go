@@ -0,0 +1,411 @@
+package tracker
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+)
+
+const history = 1000
+
+type State string
+
+const (
+	Unknown  State = ""
+	Up       State = "up"
+	Degraded State = "degraded"
+	Down     State = "down"
+)
+
+type EventType string
+
+const (
+	DownEvent      EventType = "down"
+	RecoveredEvent EventType = "recovered"
+	DegradedEvent  EventType = "degraded"
+	FlappingEvent  EventType = "flapping"
+)
+
+type Flapping struct {
+	Changes int
+	Window  time.Duration
+}
+
+type Policy struct {
+	Failures  int
+	Successes int
+
+	Flapping    Flapping
+	Renotify    time.Duration
+	Maintenance []Window
+}
+
+func (p Policy) threshold(state State) int {
+	threshold := p.Failures
+	if state == Up {
+		threshold = p.Successes
+	}
+	if threshold <= 0 {
+		return 1
+	}
+	return threshold
+}
+
+func (p Policy) maintained(at time.Time) bool {
+	for _, window := range p.Maintenance {
+		if window.Active(at) {
+			return true
+		}
+	}
+	return false
+}
+
+type Incident struct {
+	Target   string
+	State    State
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+}
+
+// Event reports a state change of a target, its job identified by Key as
+// monitor.Key does. Events raised during a maintenance window are muted,
+// reminders repeat an ongoing outage and flapping events replace the changes
+// of a target flapping between states.
+type Event struct {
+	Type     EventType
+	Target   string
+	Key      string
+	Time     time.Time
+	Result   monitor.Result
+	Incident Incident
+
+	Maintenance bool
+	Reminder    bool
+	Changes     int
+}
+
+type target struct {
+	name      string
+	method    string
+	state     State
+	failures  int
+	successes int
+	since     time.Time
+	incident  *Incident
+	last      Incident
+	incidents int
+
+	changes  []time.Time
+	flapping bool
+
+	emitted    State
+	notified   State
+	notifiedAt time.Time
+}
+
+// TargetStats are the state of the job of a target and the incidents it
+// had since it was first tracked.
+type TargetStats struct {
+	Key       string
+	Target    string
+	Method    string
+	State     State
+	Incidents int
+
+	// Since is the start of the incident still open, zero without one.
+	Since time.Time
+}
+
+type Tracker struct {
+	lock      *sync.Mutex
+	policies  map[string]Policy
+	targets   map[string]*target
+	incidents []*Incident
+
+	// updated tells the policies were replaced by Update, the results of
+	// the jobs without one are no longer tracked.
+	updated bool
+
+	results chan monitor.Result
+	events  chan Event
+}
+
+// Track updates the state of the result target and returns the event it
+// caused, if any. Results that passed count as successes, reachable ones
+// that did not as degraded and unreachable ones as down.
+func (t *Tracker) Track(result monitor.Result) (Event, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := result.Key()
+	policy, defined := t.policies[key]
+	if t.updated && !defined {
+		return Event{}, false
+	}
+	name := result.Location.String()
+	current, ok := t.targets[key]
+	if !ok {
+		current = &target{name: name, method: result.Method}
+		if current.method == "" {
+			current.method = http.MethodGet
+		}
+		t.targets[key] = current
+	}
+
+	if t.transition(name, current, policy, result) {
+		current.changes = append(current.changes, result.Time)
+	}
+	event := Event{Target: name, Key: key, Time: result.Time, Result: result, Maintenance: result.Maintenance}
+
+	flapping := current.flap(policy, result.Time)
+	switch {
+	case flapping && !current.flapping:
+		current.flapping = true
+		event.Type, event.Changes = FlappingEvent, len(current.changes)
+		return event, true
+	case flapping:
+		return Event{}, false
+	}
+	current.flapping = false
+
+	switch {
+	case current.state == Unknown:
+		return Event{}, false
+	case current.state == Up && current.notified == Unknown:
+		current.notified, current.emitted = Up, Up
+		return Event{}, false
+	case event.Maintenance && current.state != current.emitted,
+		!event.Maintenance && current.state != current.notified:
+		event.Type = kinds[current.state]
+		event.Incident = current.last
+		if current.incident != nil {
+			event.Incident = *current.incident
+		}
+	case current.state != Up && current.state == current.notified && policy.Renotify > 0 &&
+		!event.Maintenance && result.Time.Sub(current.notifiedAt) >= policy.Renotify:
+		event.Type, event.Reminder = kinds[current.state], true
+		event.Incident = *current.incident
+	default:
+		return Event{}, false
+	}
+
+	current.emitted = current.state
+	if !event.Maintenance {
+		current.notified, current.notifiedAt = current.state, result.Time
+	}
+	return event, true
+}
+
+var kinds = map[State]EventType{
+	Up:       RecoveredEvent,
+	Degraded: DegradedEvent,
+	Down:     DownEvent,
+}
+
+// transition counts the result in the streaks of the target and changes its
+// state once a streak reaches the policy threshold, opening and closing its
+// incidents.
+func (t *Tracker) transition(name string, current *target, policy Policy, result monitor.Result) bool {
+	state := classify(result)
+	if state == Up {
+		if current.successes == 0 {
+			current.since = result.Time
+		}
+		current.failures, current.successes = 0, current.successes+1
+	} else {
+		if current.failures == 0 {
+			current.since = result.Time
+		}
+		current.failures, current.successes = current.failures+1, 0
+	}
+
+	streak := current.failures
+	if state == Up {
+		streak = current.successes
+	}
+	if current.state == state || streak < policy.threshold(state) {
+		return false
+	}
+
+	previous := current.state
+	current.state = state
+	switch {
+	case state == Up && previous == Unknown:
+		return false
+	case state == Up:
+		current.incident.End = current.since
+		current.incident.Duration = current.since.Sub(current.incident.Start)
+		current.last, current.incident = *current.incident, nil
+		return true
+	case current.incident == nil:
+		current.incident = &Incident{Target: name, Start: current.since}
+		current.incidents++
+		t.incidents = append(t.incidents, current.incident)
+		if len(t.incidents) > history {
+			t.incidents = t.incidents[len(t.incidents)-history:]
+		}
+	}
+	current.incident.State = state
+	return previous != Unknown
+}
+
+// flap forgets the changes older than the flapping window and reports
+// whether the ones left are enough to consider the target flapping.
+func (c *target) flap(policy Policy, now time.Time) bool {
+	if policy.Flapping.Changes <= 0 {
+		c.changes = nil
+		return false
+	}
+	kept := c.changes[:0]
+	for _, change := range c.changes {
+		if now.Sub(change) < policy.Flapping.Window {
+			kept = append(kept, change)
+		}
+	}
+	c.changes = kept
+	return len(c.changes) >= policy.Flapping.Changes
+}
+
+func (t *Tracker) Run(wg *sync.WaitGroup, results <-chan monitor.Result) {
+	defer wg.Done()
+	for result := range results {
+		result.Maintenance = t.Maintained(result.Key(), result.Time)
+		if event, ok := t.Track(result); ok {
+			t.events <- event
+		}
+		t.results <- result
+	}
+}
+
+// Update replaces the policies, keyed by job as monitor.Key does, and
+// forgets the state of the jobs removed from the definitions along with
+// their open incidents.
+func (t *Tracker) Update(policies map[string]Policy) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.policies, t.updated = policies, true
+
+	removed := map[*Incident]bool{}
+	for key, current := range t.targets {
+		if _, ok := policies[key]; ok {
+			continue
+		}
+		if current.incident != nil {
+			removed[current.incident] = true
+		}
+		delete(t.targets, key)
+	}
+	if len(removed) == 0 {
+		return
+	}
+	kept := t.incidents[:0]
+	for _, incident := range t.incidents {
+		if !removed[incident] {
+			kept = append(kept, incident)
+		}
+	}
+	t.incidents = kept
+}
+
+func (t *Tracker) Maintained(key string, at time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.policies[key].maintained(at)
+}
+
+func (t *Tracker) State(key string) State {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if current, ok := t.targets[key]; ok {
+		return current.state
+	}
+	return Unknown
+}
+
+// Incidents returns the most recent incidents, oldest first. Incidents
+// still open have a zero end time.
+func (t *Tracker) Incidents() []Incident {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	incidents := make([]Incident, 0, len(t.incidents))
+	for _, incident := range t.incidents {
+		incidents = append(incidents, *incident)
+	}
+	return incidents
+}
+
+// Stats returns the state and incidents of every job tracked, sorted by
+// key.
+func (t *Tracker) Stats() []TargetStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	keys := make([]string, 0, len(t.targets))
+	for key := range t.targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	stats := make([]TargetStats, 0, len(keys))
+	for _, key := range keys {
+		current := t.targets[key]
+		stat := TargetStats{
+			Key:       key,
+			Target:    current.name,
+			Method:    current.method,
+			State:     current.state,
+			Incidents: current.incidents,
+		}
+		if current.incident != nil {
+			stat.Since = current.incident.Start
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+func (t *Tracker) Stop() {
+	close(t.results)
+	close(t.events)
+}
+
+func (t *Tracker) Results() <-chan monitor.Result {
+	return t.results
+}
+
+func (t *Tracker) Events() <-chan Event {
+	return t.events
+}
+
+func classify(result monitor.Result) State {
+	switch {
+	case !result.Reachable:
+		return Down
+	case !result.Passed:
+		return Degraded
+	}
+	return Up
+}
+
+func New(policies map[string]Policy) *Tracker {
+	var (
+		lock    = new(sync.Mutex)
+		targets = map[string]*target{}
+		results = make(chan monitor.Result, 100)
+		events  = make(chan Event, 100)
+	)
+	if policies == nil {
+		policies = map[string]Policy{}
+	}
+	tracker := Tracker{
+		lock:     lock,
+		policies: policies,
+		targets:  targets,
+		results:  results,
+		events:   events,
+	}
+	return &tracker
+}
@@ -0,0 +1,339 @@
+package tracker_test
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/monitor"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+var timestamp = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	up       = "up"
+	degraded = "degraded"
+	down     = "down"
+)
+
+func results(t *testing.T, states ...string) []monitor.Result {
+	location, err := url.Parse("https://domain.com")
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	results := make([]monitor.Result, 0, len(states))
+	for i, state := range states {
+		result := monitor.Result{
+			Location:  location,
+			Time:      timestamp.Add(time.Duration(i) * time.Minute),
+			Reachable: state != down,
+			Passed:    state == up,
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func TestTrack(t *testing.T) {
+	tests := map[string]struct {
+		policy tracker.Policy
+		states []string
+		want   []tracker.EventType
+	}{
+		"healthy": {
+			states: []string{up, up, up},
+		},
+		"down and recovered": {
+			states: []string{up, down, up},
+			want:   []tracker.EventType{tracker.DownEvent, tracker.RecoveredEvent},
+		},
+		"down from the start": {
+			states: []string{down, down},
+			want:   []tracker.EventType{tracker.DownEvent},
+		},
+		"degraded then down": {
+			states: []string{up, degraded, down, down, up},
+			want:   []tracker.EventType{tracker.DegradedEvent, tracker.DownEvent, tracker.RecoveredEvent},
+		},
+		"below the failures threshold": {
+			policy: tracker.Policy{Failures: 3},
+			states: []string{up, down, down, up, down, down},
+		},
+		"failures threshold": {
+			policy: tracker.Policy{Failures: 3},
+			states: []string{up, down, degraded, down, up},
+			want:   []tracker.EventType{tracker.DownEvent, tracker.RecoveredEvent},
+		},
+		"successes threshold": {
+			policy: tracker.Policy{Successes: 2},
+			states: []string{up, down, up, down, up, up},
+			want:   []tracker.EventType{tracker.DownEvent, tracker.RecoveredEvent},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sut := tracker.New(map[string]tracker.Policy{"GET https://domain.com": test.policy})
+
+			var got []tracker.EventType
+			for _, result := range results(t, test.states...) {
+				if event, ok := sut.Track(result); ok {
+					got = append(got, event.Type)
+				}
+			}
+
+			if !reflect.DeepEqual(test.want, got) {
+				msg := "\nwant %v\n  got %v"
+				t.Fatalf(msg, test.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackIncidents(t *testing.T) {
+	sut := tracker.New(map[string]tracker.Policy{"GET https://domain.com": {Failures: 2, Successes: 2}})
+
+	var events []tracker.Event
+	for _, result := range results(t, up, down, degraded, down, up, up, down) {
+		if event, ok := sut.Track(result); ok {
+			events = append(events, event)
+		}
+	}
+
+	want := tracker.Incident{
+		Target:   "https://domain.com",
+		State:    tracker.Down,
+		Start:    timestamp.Add(time.Minute),
+		End:      timestamp.Add(4 * time.Minute),
+		Duration: 3 * time.Minute,
+	}
+	if len(events) != 3 {
+		msg := "want 3 events, got %v"
+		t.Fatalf(msg, events)
+	}
+	if got := events[2].Incident; got != want {
+		msg := "\nwant %+v\n  got %+v"
+		t.Fatalf(msg, want, got)
+	}
+
+	incidents := sut.Incidents()
+	if len(incidents) != 1 || incidents[0] != want {
+		msg := "want only the closed incident %+v, got %+v"
+		t.Fatalf(msg, want, incidents)
+	}
+	if state := sut.State("GET https://domain.com"); state != tracker.Up {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, tracker.Up, state)
+	}
+}
+
+func TestTrackMethods(t *testing.T) {
+	sut := tracker.New(map[string]tracker.Policy{
+		"GET https://domain.com":  {Failures: 1},
+		"POST https://domain.com": {Failures: 2},
+	})
+
+	// The results of both jobs interleave, each keeps its own streak and
+	// policy.
+	gets, posts := results(t, up, down, down), results(t, up, up, down)
+	events := []tracker.Event{}
+	for i := range gets {
+		posts[i].Method = "POST"
+		for _, result := range []monitor.Result{gets[i], posts[i]} {
+			if event, ok := sut.Track(result); ok {
+				events = append(events, event)
+			}
+		}
+	}
+
+	if len(events) != 1 || events[0].Key != "GET https://domain.com" || events[0].Type != tracker.DownEvent {
+		msg := "want only the GET job down, got %+v"
+		t.Fatalf(msg, events)
+	}
+	if state := sut.State("POST https://domain.com"); state != tracker.Up {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, tracker.Up, state)
+	}
+}
+
+func TestRun(t *testing.T) {
+	sut := tracker.New(nil)
+
+	results := results(t, up, down, up)
+	input := make(chan monitor.Result, len(results))
+	for _, result := range results {
+		input <- result
+	}
+	close(input)
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go sut.Run(wg, input)
+	wg.Wait()
+	sut.Stop()
+
+	forwarded := 0
+	for range sut.Results() {
+		forwarded++
+	}
+	if forwarded != len(results) {
+		msg := "want %d forwarded results, got %d"
+		t.Fatalf(msg, len(results), forwarded)
+	}
+
+	var events []tracker.EventType
+	for event := range sut.Events() {
+		events = append(events, event.Type)
+	}
+	want := []tracker.EventType{tracker.DownEvent, tracker.RecoveredEvent}
+	if !reflect.DeepEqual(want, events) {
+		msg := "\nwant %v\n  got %v"
+		t.Fatalf(msg, want, events)
+	}
+}
+
+func TestTrackFlapping(t *testing.T) {
+	policy := tracker.Policy{Flapping: tracker.Flapping{Changes: 3, Window: 10 * time.Minute}}
+	sut := tracker.New(map[string]tracker.Policy{"GET https://domain.com": policy})
+
+	states := []string{up, down, up, down, up, down}
+	for i := 0; i < 8; i++ {
+		states = append(states, down)
+	}
+
+	var got []string
+	for _, result := range results(t, states...) {
+		if event, ok := sut.Track(result); ok {
+			got = append(got, fmt.Sprintf("%s at %s", event.Type, event.Time.Sub(timestamp)))
+		}
+	}
+
+	want := []string{"down at 1m0s", "recovered at 2m0s", "flapping at 3m0s", "down at 13m0s"}
+	if !reflect.DeepEqual(want, got) {
+		msg := "\nwant %v\n  got %v"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestTrackRenotify(t *testing.T) {
+	policy := tracker.Policy{Renotify: 5 * time.Minute}
+	sut := tracker.New(map[string]tracker.Policy{"GET https://domain.com": policy})
+
+	states := []string{up}
+	for i := 0; i < 12; i++ {
+		states = append(states, down)
+	}
+	states = append(states, up)
+
+	var got []string
+	for _, result := range results(t, states...) {
+		if event, ok := sut.Track(result); ok {
+			got = append(got, fmt.Sprintf("%s at %s reminder %t", event.Type, event.Time.Sub(timestamp), event.Reminder))
+		}
+	}
+
+	want := []string{
+		"down at 1m0s reminder false",
+		"down at 6m0s reminder true",
+		"down at 11m0s reminder true",
+		"recovered at 13m0s reminder false",
+	}
+	if !reflect.DeepEqual(want, got) {
+		msg := "\nwant %v\n  got %v"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestRunMaintenance(t *testing.T) {
+	window := tracker.Period{Start: timestamp.Add(time.Minute), End: timestamp.Add(4 * time.Minute)}
+	policy := tracker.Policy{Maintenance: []tracker.Window{window}}
+	sut := tracker.New(map[string]tracker.Policy{"GET https://domain.com": policy})
+
+	results := results(t, up, down, up, down, down, down)
+	input := make(chan monitor.Result, len(results))
+	for _, result := range results {
+		input <- result
+	}
+	close(input)
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go sut.Run(wg, input)
+	wg.Wait()
+	sut.Stop()
+
+	var marked []bool
+	for result := range sut.Results() {
+		marked = append(marked, result.Maintenance)
+	}
+	if want := []bool{false, true, true, true, false, false}; !reflect.DeepEqual(want, marked) {
+		msg := "\nwant %v\n  got %v"
+		t.Fatalf(msg, want, marked)
+	}
+
+	var events []string
+	for event := range sut.Events() {
+		events = append(events, fmt.Sprintf("%s muted %t", event.Type, event.Maintenance))
+	}
+	want := []string{"down muted true", "recovered muted true", "down muted true", "down muted false"}
+	if !reflect.DeepEqual(want, events) {
+		msg := "\nwant %v\n  got %v"
+		t.Fatalf(msg, want, events)
+	}
+}
+
+func TestStats(t *testing.T) {
+	sut := tracker.New(nil)
+	for _, result := range results(t, up, down, up, degraded) {
+		sut.Track(result)
+	}
+
+	want := []tracker.TargetStats{{
+		Key:       "GET https://domain.com",
+		Target:    "https://domain.com",
+		Method:    "GET",
+		State:     tracker.Degraded,
+		Incidents: 2,
+		Since:     timestamp.Add(3 * time.Minute),
+	}}
+	if got := sut.Stats(); !reflect.DeepEqual(want, got) {
+		msg := "\nwant %+v\n  got %+v"
+		t.Fatalf(msg, want, got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	sut := tracker.New(nil)
+	kept, removed := results(t, up, down), results(t, up, down)
+	for i := range removed {
+		removed[i].Method = "POST"
+		sut.Track(kept[i])
+		sut.Track(removed[i])
+	}
+
+	sut.Update(map[string]tracker.Policy{"GET https://domain.com": {}})
+	// A result of the removed job still in flight does not bring its state
+	// back.
+	if _, ok := sut.Track(removed[0]); ok {
+		t.Fatal("want no event of the removed job, got one")
+	}
+
+	stats := sut.Stats()
+	if len(stats) != 1 || stats[0].Key != "GET https://domain.com" {
+		msg := "want only the kept job, got %+v"
+		t.Fatalf(msg, stats)
+	}
+	if state := sut.State("POST https://domain.com"); state != tracker.Unknown {
+		msg := "want %q, got %q"
+		t.Fatalf(msg, tracker.Unknown, state)
+	}
+	incidents := sut.Incidents()
+	if len(incidents) != 1 || incidents[0].Start != kept[1].Time {
+		msg := "want only the incident of the kept job, got %+v"
+		t.Fatalf(msg, incidents)
+	}
+}
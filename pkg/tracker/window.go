@@ -0,0 +1,59 @@
+package tracker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ksahli/baal/pkg/ticker"
+)
+
+type Window interface {
+	Active(at time.Time) bool
+}
+
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (p Period) Active(at time.Time) bool {
+	return !at.Before(p.Start) && at.Before(p.End)
+}
+
+// ParsePeriod reads a period written as two RFC 3339 times separated by a
+// slash, such as 2022-01-01T02:00:00Z/2022-01-01T04:00:00Z.
+func ParsePeriod(spec string) (Period, error) {
+	bounds := strings.SplitN(spec, "/", 2)
+	if len(bounds) != 2 {
+		err := fmt.Errorf("period %q: want start/end", spec)
+		return Period{}, err
+	}
+	start, err := time.Parse(time.RFC3339, bounds[0])
+	if err != nil {
+		err := fmt.Errorf("period %q: invalid start: %w", spec, err)
+		return Period{}, err
+	}
+	end, err := time.Parse(time.RFC3339, bounds[1])
+	if err != nil {
+		err := fmt.Errorf("period %q: invalid end: %w", spec, err)
+		return Period{}, err
+	}
+	if !end.After(start) {
+		err := fmt.Errorf("period %q: end is not after start", spec)
+		return Period{}, err
+	}
+	return Period{Start: start, End: end}, nil
+}
+
+type Recurring struct {
+	Schedule ticker.Schedule
+	Duration time.Duration
+}
+
+// Active reports whether the schedule started a window in the duration
+// leading up to at.
+func (r Recurring) Active(at time.Time) bool {
+	start := r.Schedule.Next(at.Add(-r.Duration))
+	return !start.IsZero() && !start.After(at)
+}
@@ -0,0 +1,77 @@
+package tracker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/ticker"
+	"github.com/ksahli/baal/pkg/tracker"
+)
+
+func TestParsePeriod(t *testing.T) {
+	got, err := tracker.ParsePeriod("2022-01-01T02:00:00Z/2022-01-01T04:00:00+01:00")
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	tests := map[string]struct {
+		at   time.Time
+		want bool
+	}{
+		"before": {at: time.Date(2022, 1, 1, 1, 59, 0, 0, time.UTC)},
+		"start":  {at: time.Date(2022, 1, 1, 2, 0, 0, 0, time.UTC), want: true},
+		"during": {at: time.Date(2022, 1, 1, 2, 59, 0, 0, time.UTC), want: true},
+		"end":    {at: time.Date(2022, 1, 1, 3, 0, 0, 0, time.UTC)},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if active := got.Active(test.at); active != test.want {
+				msg := "want active %t at %s, got %t"
+				t.Fatalf(msg, test.want, test.at, active)
+			}
+		})
+	}
+}
+
+func TestParsePeriodError(t *testing.T) {
+	for _, spec := range []string{
+		"2022-01-01T02:00:00Z",
+		"yesterday/2022-01-01T04:00:00Z",
+		"2022-01-01T02:00:00Z/tomorrow",
+		"2022-01-01T04:00:00Z/2022-01-01T02:00:00Z",
+	} {
+		if _, err := tracker.ParsePeriod(spec); err == nil {
+			msg := "want an error for %q, got nothing"
+			t.Fatalf(msg, spec)
+		}
+	}
+}
+
+func TestRecurring(t *testing.T) {
+	schedule, err := ticker.ParseCron("0 2 * * SUN", time.UTC)
+	if err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+	window := tracker.Recurring{Schedule: schedule, Duration: 2 * time.Hour}
+
+	tests := map[string]struct {
+		at   time.Time
+		want bool
+	}{
+		"sunday before": {at: time.Date(2022, 1, 2, 1, 59, 0, 0, time.UTC)},
+		"sunday start":  {at: time.Date(2022, 1, 2, 2, 0, 0, 0, time.UTC), want: true},
+		"sunday during": {at: time.Date(2022, 1, 2, 3, 30, 0, 0, time.UTC), want: true},
+		"sunday after":  {at: time.Date(2022, 1, 2, 4, 0, 0, 0, time.UTC)},
+		"monday":        {at: time.Date(2022, 1, 3, 3, 0, 0, 0, time.UTC)},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if active := window.Active(test.at); active != test.want {
+				msg := "want active %t at %s, got %t"
+				t.Fatalf(msg, test.want, test.at, active)
+			}
+		})
+	}
+}
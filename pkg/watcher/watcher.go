@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+type Watcher struct {
+	path     string
+	interval time.Duration
+	signals  <-chan os.Signal
+	changes  chan struct{}
+
+	size     int64
+	modified time.Time
+}
+
+func (w *Watcher) Watch(ctx context.Context) {
+	defer close(w.changes)
+
+	var poll <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-poll:
+			if !w.changed() {
+				continue
+			}
+		case <-w.signals:
+			w.changed()
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case w.changes <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// changed compares the file size and modification time with the last ones
+// seen. A missing file is not a change, so a file being replaced is picked
+// up on the next poll once it is back.
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	if info.Size() == w.size && info.ModTime().Equal(w.modified) {
+		return false
+	}
+	w.size, w.modified = info.Size(), info.ModTime()
+	return true
+}
+
+func New(path string, interval time.Duration, signals <-chan os.Signal) *Watcher {
+	changes := make(chan struct{}, 1)
+	watcher := Watcher{
+		path:     path,
+		interval: interval,
+		signals:  signals,
+		changes:  changes,
+	}
+	watcher.changed()
+	return &watcher
+}
@@ -0,0 +1,99 @@
+package watcher_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ksahli/baal/pkg/watcher"
+)
+
+var ctx = context.Background()
+
+func write(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := fmt.Sprintf("%s/definitions.json", t.TempDir())
+	write(t, path, "[]")
+
+	watcher := watcher.New(path, 10*time.Millisecond, nil)
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	go watcher.Watch(ctx)
+
+	select {
+	case <-watcher.Changes():
+		t.Fatal("want no change before the file is written")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	write(t, path, `[{"location": "https://domain.com"}]`)
+
+	select {
+	case <-watcher.Changes():
+	case <-ctx.Done():
+		t.Fatal("want a change after the file is written, got nothing")
+	}
+}
+
+func TestWatchMissing(t *testing.T) {
+	path := fmt.Sprintf("%s/definitions.json", t.TempDir())
+	write(t, path, "[]")
+
+	watcher := watcher.New(path, 10*time.Millisecond, nil)
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	go watcher.Watch(ctx)
+
+	if err := os.Remove(path); err != nil {
+		msg := "unwanted error: %v"
+		t.Fatalf(msg, err)
+	}
+
+	select {
+	case <-watcher.Changes():
+		t.Fatal("want no change while the file is missing")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	write(t, path, `[{"location": "https://domain.com"}]`)
+
+	select {
+	case <-watcher.Changes():
+	case <-ctx.Done():
+		t.Fatal("want a change once the file is back, got nothing")
+	}
+}
+
+func TestWatchSignal(t *testing.T) {
+	path := fmt.Sprintf("%s/definitions.json", t.TempDir())
+	write(t, path, "[]")
+
+	signals := make(chan os.Signal, 1)
+	watcher := watcher.New(path, 0, signals)
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	go watcher.Watch(ctx)
+
+	signals <- syscall.SIGHUP
+
+	select {
+	case <-watcher.Changes():
+	case <-ctx.Done():
+		t.Fatal("want a change after a signal, got nothing")
+	}
+
+	cancel()
+	for range watcher.Changes() {
+	}
+}